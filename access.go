@@ -0,0 +1,148 @@
+package colorjson
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// AccessLogFormat selects an access log layout for HTTP request records.
+type AccessLogFormat int
+
+const (
+	AccessLogOff      AccessLogFormat = iota // HTTP request records are written like any other (default)
+	AccessLogCommon                          // Apache common log format
+	AccessLogCombined                        // Apache combined log format, common with the referer and user agent
+)
+
+// accessTimeFormat is the time layout of the Apache log formats.
+const accessTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLine writes r as an access log line when it has an "http" group
+// holding at least a method, path and status, as written by LogMiddleware.
+// The request line uses the "uri" attr, if any, in place of the path, and
+// "HTTP/1.1" if the group has no "proto".
+// It reports false, leaving e untouched, for other records.
+func (h *ColorJSONHandler) accessLine(e *encoder, r slog.Record) bool {
+	var req []slog.Attr
+	for _, a := range h.collect(r) {
+		if a.Key == "http" && a.Value.Kind() == slog.KindGroup {
+			req = a.Value.Group()
+		}
+	}
+	get := func(key string) slog.Value {
+		for _, a := range req {
+			if a.Key == key {
+				return a.Value
+			}
+		}
+		return slog.Value{}
+	}
+	method, path, status := get("method"), get("path"), get("status")
+	if method.Kind() != slog.KindString || path.Kind() != slog.KindString {
+		return false
+	}
+	var code StatusCode
+	switch status.Kind() {
+	case slog.KindInt64:
+		code = HTTPStatus(int(status.Int64()))
+	case slog.KindAny:
+		var ok bool
+		if code, ok = status.Any().(StatusCode); !ok {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// host ident authuser [time] "request" status bytes
+	e.accessField(e.colors.Key, get("remote"))
+	e.buf = append(e.buf, " - "...)
+	e.accessField(e.colors.String, get("user"))
+	e.buf = append(e.buf, ' ')
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if h.TimeLocation != nil {
+		t = t.In(h.TimeLocation)
+	}
+	e.appendColor(e.colors.time())
+	e.buf = append(e.buf, '[')
+	e.buf = t.AppendFormat(e.buf, accessTimeFormat)
+	e.buf = append(e.buf, ']')
+	e.reset(e.colors.time())
+	e.buf = append(e.buf, ' ')
+	// the request line as sent, with the query string the path lacks, and
+	// always three fields, as log analyzers expect, so a decoded path has
+	// its spaces and quotes escaped again
+	target := path.String()
+	if uri := get("uri"); uri.Kind() == slog.KindString && uri.String() != "" {
+		target = uri.String()
+	}
+	proto := "HTTP/1.1"
+	if p := get("proto"); p.Kind() == slog.KindString && p.String() != "" {
+		proto = p.String()
+	}
+	request := method.String() + " " + escapeTarget(target) + " " + proto
+	e.colored(e.colors.String, strconv.AppendQuote(nil, request))
+	e.buf = append(e.buf, ' ')
+	e.appendInt(e.statusColor(code), int64(code.Code))
+	e.buf = append(e.buf, ' ')
+	if n := get("bytes"); n.Kind() == slog.KindInt64 && n.Int64() > 0 {
+		e.appendInt(e.colors.Number, n.Int64())
+	} else {
+		e.buf = append(e.buf, '-')
+	}
+	if h.AccessLog == AccessLogCombined {
+		e.buf = append(e.buf, ' ')
+		e.accessQuoted(get("referer"))
+		e.buf = append(e.buf, ' ')
+		e.accessQuoted(get("user_agent"))
+	}
+	e.buf = append(e.buf, e.eol...)
+	return true
+}
+
+// accessField writes an unquoted access log field, "-" when v is empty.
+func (e *encoder) accessField(c TerminalColor, v slog.Value) {
+	if v.Kind() != slog.KindString || v.String() == "" {
+		e.buf = append(e.buf, '-')
+		return
+	}
+	e.colored(c, []byte(v.String()))
+}
+
+// accessQuoted writes a quoted access log field, "-" when v is empty.
+func (e *encoder) accessQuoted(v slog.Value) {
+	s := "-"
+	if v.Kind() == slog.KindString && v.String() != "" {
+		s = v.String()
+	}
+	e.colored(e.colors.String, strconv.AppendQuote(nil, s))
+}
+
+// escapeTarget percent-encodes the bytes of a request target that would
+// split or end the quoted request line: spaces, quotes, backslashes,
+// control characters and non-ASCII bytes.
+func escapeTarget(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b []byte // allocated on the first escape
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c > ' ' && c < 0x7f && c != '"' && c != '\\' {
+			if b != nil {
+				b = append(b, c)
+			}
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(s)+8), s[:i]...)
+		}
+		b = append(b, '%', hex[c>>4], hex[c&0xf])
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
@@ -0,0 +1,60 @@
+package colorjson
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		format AccessLogFormat
+		attrs  []slog.Attr
+		want   string
+	}{
+		{
+			AccessLogCommon,
+			[]slog.Attr{slog.String("method", "GET"), slog.String("path", "/a"), slog.Int("status", 200), slog.Int64("bytes", 5), slog.String("remote", "10.0.0.1")},
+			`10.0.0.1 - - [01/May/2024:12:00:00 +0000] "GET /a HTTP/1.1" 200 5`,
+		},
+		{
+			AccessLogCommon,
+			[]slog.Attr{slog.String("method", "GET"), slog.String("path", "/a"), slog.String("uri", "/a?x=1"), slog.Any("status", HTTPStatus(404)), slog.String("proto", "HTTP/2.0")},
+			`- - - [01/May/2024:12:00:00 +0000] "GET /a?x=1 HTTP/2.0" 404 -`,
+		},
+		{
+			AccessLogCommon,
+			[]slog.Attr{slog.String("method", "GET"), slog.String("path", `/a b/"c"\d/é`), slog.Int("status", 200)},
+			`- - - [01/May/2024:12:00:00 +0000] "GET /a%20b/%22c%22%5Cd/%C3%A9 HTTP/1.1" 200 -`,
+		},
+		{
+			AccessLogCombined,
+			[]slog.Attr{slog.String("method", "POST"), slog.String("path", "/"), slog.Int("status", 500), slog.String("user_agent", `curl "8"`)},
+			`- - - [01/May/2024:12:00:00 +0000] "POST / HTTP/1.1" 500 - "-" "curl \"8\""`,
+		},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, nil)
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		h.AccessLog = tt.format
+		r := slog.NewRecord(ts, slog.LevelInfo, "http request", 0)
+		r.AddAttrs(slog.Attr{Key: "http", Value: slog.GroupValue(tt.attrs...)})
+		if err := h.Handle(t.Context(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tt.want {
+			t.Errorf("got  %s\nwant %s", got, tt.want)
+		}
+	}
+
+	// records without a request are written as usual
+	matchesJSONHandler(t, func(l *slog.Logger) {
+		if h, ok := l.Handler().(*ColorJSONHandler); ok {
+			h.AccessLog = AccessLogCombined
+		}
+		l.Info("m", slog.Group("http", "method", "GET"))
+	})
+}
@@ -0,0 +1,109 @@
+package colorjson
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultMessageWidth is the width messages are padded to by AlignColumns
+// when MessageWidth is zero.
+const defaultMessageWidth = 40
+
+// alignState holds the widest time, level and source written so far, the
+// widths AlignColumns pads them to. It is shared by the handlers derived
+// with WithAttrs and WithGroup.
+type alignState struct {
+	time, level, source atomic.Int64
+}
+
+// width returns the width the column of the built-in attr key is padded
+// to, after one of width w was written.
+func (s *alignState) width(key string, w, msgWidth int) int {
+	var max *atomic.Int64
+	switch key {
+	case slog.MessageKey:
+		return msgWidth
+	case slog.TimeKey:
+		max = &s.time
+	case slog.LevelKey:
+		max = &s.level
+	case slog.SourceKey:
+		max = &s.source
+	default:
+		return 0
+	}
+	for {
+		m := max.Load()
+		if int64(w) <= m {
+			return int(m)
+		}
+		if max.CompareAndSwap(m, int64(w)) {
+			return w
+		}
+	}
+}
+
+// alignedBuiltin writes a built-in attr and sets the padding written before
+// the next column so it ends at the width of its column.
+func (e *encoder) alignedBuiltin(a slog.Attr) {
+	e.seedColumn(a)
+	pending := e.pad
+	start := len(e.buf)
+	e.writeBuiltin(a)
+	if e.pad != 0 {
+		// nothing was written, the padding is still due
+		return
+	}
+	w := visibleWidth(e.buf[start:]) - pending
+	e.pad = max(e.align.width(a.Key, w, e.msgWidth)-w, 0)
+}
+
+// seedColumn sets the width of a time or level column, before the first
+// one is written, from its widest usual value: a time with all nine
+// fraction digits or the ERROR level. The first lines then align too.
+func (e *encoder) seedColumn(a slog.Attr) {
+	var widest slog.Attr
+	switch {
+	case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime && e.align.time.Load() == 0:
+		t := a.Value.Time()
+		widest = slog.Time(a.Key, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 999999999, t.Location()))
+	case a.Key == slog.LevelKey && a.Value.Kind() == slog.KindAny && e.align.level.Load() == 0:
+		if _, ok := a.Value.Any().(slog.Level); !ok {
+			return
+		}
+		widest = slog.Any(a.Key, slog.LevelError)
+	default:
+		return
+	}
+	// written and measured, then taken back
+	n, empty, pad, depth := len(e.buf), e.empty, e.pad, e.depth
+	e.pad = 0
+	e.writeBuiltin(widest)
+	e.align.width(a.Key, visibleWidth(e.buf[n:]), 0)
+	e.buf, e.empty, e.pad, e.depth = e.buf[:n], empty, pad, depth
+}
+
+// padColumn writes the padding due before the next column.
+func (e *encoder) padColumn() {
+	for ; e.pad > 0; e.pad-- {
+		e.buf = append(e.buf, ' ')
+	}
+}
+
+// visibleWidth returns the number of characters b shows on a terminal,
+// leaving out escape sequences.
+func visibleWidth(b []byte) int {
+	n := 0
+	for i := 0; i < len(b); {
+		if m := escapeLen(b, i); m > 0 {
+			i += m
+			continue
+		}
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+		n++
+	}
+	return n
+}
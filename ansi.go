@@ -0,0 +1,179 @@
+package colorjson
+
+import (
+	"strconv"
+	"strings"
+)
+
+// stripANSI removes ANSI escape sequences from p: CSI sequences such as
+// colors, and OSC sequences such as the hyperlinks of SourceLink.
+func stripANSI(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		end := escapeEnd(p, i)
+		if end < 0 {
+			out = append(out, p[i])
+			continue
+		}
+		i = end
+	}
+	return out
+}
+
+// escapeEnd returns the index of the last byte of the CSI or OSC sequence
+// starting at p[i], or -1 if none starts there. A sequence cut short ends
+// with p.
+func escapeEnd[T string | []byte](p T, i int) int {
+	if p[i] != '\033' || i+1 >= len(p) {
+		return -1
+	}
+	switch p[i+1] {
+	case '[':
+		// parameter and intermediate bytes up to the final byte
+		i += 2
+		for i < len(p) && (p[i] < 0x40 || p[i] > 0x7e) {
+			i++
+		}
+		return min(i, len(p)-1)
+	case ']':
+		// anything up to BEL or ST (ESC \)
+		for i += 2; i < len(p); i++ {
+			if p[i] == '\a' {
+				return i
+			}
+			if p[i] == '\033' && i+1 < len(p) && p[i+1] == '\\' {
+				return i + 1
+			}
+		}
+		return len(p) - 1
+	}
+	return -1
+}
+
+// ansiPalette holds the RGB values of the 16 basic ANSI colors (xterm defaults)
+var ansiPalette = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xcd, 0x00, 0x00}, {0x00, 0xcd, 0x00}, {0xcd, 0xcd, 0x00},
+	{0x00, 0x00, 0xee}, {0xcd, 0x00, 0xcd}, {0x00, 0xcd, 0xcd}, {0xe5, 0xe5, 0xe5},
+	{0x7f, 0x7f, 0x7f}, {0xff, 0x00, 0x00}, {0x00, 0xff, 0x00}, {0xff, 0xff, 0x00},
+	{0x5c, 0x5c, 0xff}, {0xff, 0x00, 0xff}, {0x00, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// ansi256RGB returns the RGB value of a color of the 256-color palette.
+func ansi256RGB(n uint8) [3]uint8 {
+	switch {
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		n -= 16
+		return [3]uint8{levels[n/36], levels[n/6%6], levels[n%6]}
+	default:
+		g := 8 + 10*(n-232)
+		return [3]uint8{g, g, g}
+	}
+}
+
+// sgrState is the text style resulting from a series of SGR escape sequences.
+type sgrState struct {
+	fg, bg                       *[3]uint8 // nil for the default color
+	bold, dim, italic, underline bool
+}
+
+// apply updates the state with the parameters of one SGR sequence, e.g. "1;31".
+func (s *sgrState) apply(params string) {
+	if params == "" {
+		params = "0"
+	}
+	ps := strings.Split(params, ";")
+	for i := 0; i < len(ps); i++ {
+		p, err := strconv.Atoi(ps[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case p == 0:
+			*s = sgrState{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.dim = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 22:
+			s.bold, s.dim = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p >= 30 && p <= 37:
+			s.fg = &ansiPalette[p-30]
+		case p >= 90 && p <= 97:
+			s.fg = &ansiPalette[p-90+8]
+		case p == 39:
+			s.fg = nil
+		case p >= 40 && p <= 47:
+			s.bg = &ansiPalette[p-40]
+		case p >= 100 && p <= 107:
+			s.bg = &ansiPalette[p-100+8]
+		case p == 49:
+			s.bg = nil
+		case p == 38 || p == 48:
+			var rgb [3]uint8
+			switch {
+			case i+2 < len(ps) && ps[i+1] == "5":
+				n, _ := strconv.Atoi(ps[i+2])
+				rgb = ansi256RGB(uint8(n))
+				i += 2
+			case i+4 < len(ps) && ps[i+1] == "2":
+				for j := range rgb {
+					v, _ := strconv.Atoi(ps[i+2+j])
+					rgb[j] = uint8(v)
+				}
+				i += 4
+			default:
+				continue
+			}
+			if p == 38 {
+				s.fg = &rgb
+			} else {
+				s.bg = &rgb
+			}
+		}
+	}
+}
+
+// styledSpan is a run of text with a single style.
+type styledSpan struct {
+	text  string
+	style sgrState
+}
+
+// parseANSI splits a line of text containing SGR escape sequences into
+// styled spans. Other escape sequences are dropped.
+func parseANSI(line string) []styledSpan {
+	var spans []styledSpan
+	var st sgrState
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, styledSpan{text: text.String(), style: st})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		j := escapeEnd(line, i)
+		if j < 0 {
+			text.WriteByte(line[i])
+			continue
+		}
+		if line[i+1] == '[' && line[j] == 'm' {
+			flush()
+			st.apply(line[i+2 : j])
+		}
+		i = j
+	}
+	flush()
+	return spans
+}
@@ -0,0 +1,22 @@
+package colorjson
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"\033[1;31mred\033[0m", "red"},
+		{"\033[38;2;1;2;3mrgb\033[m", "rgb"},
+		{"\033]8;;file:///a.go\033\\a.go:1\033]8;;\033\\", "a.go:1"},
+		{"\033]8;;https://x\alink\033]8;;\a", "link"},
+		{"cut \033[31", "cut "},
+		{"cut \033]8;;file://", "cut "},
+		{"lone \033", "lone \033"},
+	} {
+		if got := string(stripANSI([]byte(tt.in))); got != tt.want {
+			t.Errorf("stripANSI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package colorjson
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Background is the brightness of the terminal's background.
+type Background int
+
+const (
+	BackgroundUnknown Background = iota
+	BackgroundDark
+	BackgroundLight
+)
+
+// lightColors keeps every value readable on a white background, where the
+// default white, bright and cyan colors fade away.
+var lightColors = Colors{
+	String:     Style{Fg: Color256(28)}.Color(),  // dark green
+	Number:     Style{Fg: Color256(130)}.Color(), // brown
+	Boolean:    Style{Fg: Color256(90)}.Color(),  // purple
+	Null:       GrayColor,
+	Key:        Style{Fg: Color256(25)}.Color(), // dark blue
+	Brace:      BlueColor,
+	Error:      Style{Fg: Color256(160)}.Color(), // red
+	Stack:      GrayColor,
+	Duration:   Style{Fg: Color256(31)}.Color(), // teal
+	Dim:        DimColor,
+	LevelInfo:  Style{Fg: Black, Bold: true}.Color(),
+	LevelDebug: Style{Fg: Color256(31), Bold: true}.Color(),
+	LevelWarn:  Style{Fg: Color256(130), Bold: true}.Color(),
+	LevelError: Style{Fg: Color256(160), Bold: true}.Color(),
+}
+
+// envColors are the colors of a new handler: the "light" theme when
+// COLORFGBG reports a light background, the default theme otherwise.
+var envColors = sync.OnceValue(func() Colors {
+	if colorFGBG() == BackgroundLight {
+		return lightColors
+	}
+	return defaultColors
+})
+
+// DetectBackground reports whether the terminal has a dark or light
+// background, from the COLORFGBG environment variable set by some terminals
+// or else by asking the terminal with an OSC 11 query, waiting up to
+// timeout for the answer. The query is only supported on linux.
+func DetectBackground(timeout time.Duration) Background {
+	if b := colorFGBG(); b != BackgroundUnknown {
+		return b
+	}
+	resp, ok := queryBackground(timeout)
+	if !ok {
+		return BackgroundUnknown
+	}
+	return parseOSC11(resp)
+}
+
+// AutoTheme returns the "light" theme on a light background and the
+// default theme otherwise. See DetectBackground.
+func AutoTheme(timeout time.Duration) Colors {
+	if DetectBackground(timeout) == BackgroundLight {
+		return lightColors
+	}
+	return defaultColors
+}
+
+// colorFGBG reads the background from COLORFGBG, e.g. "15;0" for white on
+// black. The last field is the background's ANSI color index.
+func colorFGBG() Background {
+	v := os.Getenv("COLORFGBG")
+	if v == "" {
+		return BackgroundUnknown
+	}
+	n, err := strconv.Atoi(v[strings.LastIndexByte(v, ';')+1:])
+	switch {
+	case err != nil || n < 0 || n > 15:
+		return BackgroundUnknown
+	case n == 7 || n > 8:
+		return BackgroundLight
+	}
+	return BackgroundDark
+}
+
+// parseOSC11 parses a terminal's answer to an OSC 11 query, such as
+// "\033]11;rgb:ffff/ffff/ffff\033\\", into the brightness of the color.
+func parseOSC11(resp string) Background {
+	_, rgb, ok := strings.Cut(resp, "rgb:")
+	if !ok {
+		return BackgroundUnknown
+	}
+	rgb = strings.TrimRight(rgb, "\a\033\\")
+	parts := strings.Split(rgb, "/")
+	if len(parts) != 3 {
+		return BackgroundUnknown
+	}
+	var c [3]float64
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 16, 16)
+		if err != nil || len(p) == 0 || len(p) > 4 {
+			return BackgroundUnknown
+		}
+		c[i] = float64(n) / float64(uint64(1)<<(4*len(p))-1)
+	}
+	// relative luminance, ignoring gamma
+	if 0.2126*c[0]+0.7152*c[1]+0.0722*c[2] > 0.5 {
+		return BackgroundLight
+	}
+	return BackgroundDark
+}
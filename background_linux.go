@@ -0,0 +1,51 @@
+package colorjson
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// queryBackground sends an OSC 11 query to the controlling terminal and
+// returns its answer. The terminal is put in raw mode while waiting so the
+// answer is not echoed.
+func queryBackground(timeout time.Duration) (string, bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false
+	}
+	defer tty.Close()
+	fd := tty.Fd()
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&old))); errno != 0 {
+		return "", false
+	}
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1 // reads return after 100ms without input
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); errno != 0 {
+		return "", false
+	}
+	defer syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&old)))
+
+	if _, err := tty.WriteString("\033]11;?\033\\"); err != nil {
+		return "", false
+	}
+	var resp []byte
+	buf := make([]byte, 64)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		n, err := tty.Read(buf)
+		if err != nil {
+			break
+		}
+		resp = append(resp, buf[:n]...)
+		// the answer ends with BEL or ST
+		if s := string(resp); strings.HasSuffix(s, "\a") || strings.HasSuffix(s, "\033\\") {
+			return s, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package colorjson
+
+import "time"
+
+// queryBackground is only supported on linux.
+func queryBackground(timeout time.Duration) (string, bool) {
+	return "", false
+}
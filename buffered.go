@@ -0,0 +1,109 @@
+package colorjson
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// BufferedWriter collects writes in memory and passes them to the underlying
+// writer when the buffer is full, every flush interval and on Flush, reducing
+// syscalls when logging at high volume. Records are never split between
+// flushes.
+type BufferedWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	buf  []byte
+	size int
+	done chan struct{}
+	wg   sync.WaitGroup
+	err  error // first error from a periodic flush
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewBufferedWriter creates a BufferedWriter with a buffer of size bytes
+// (64 KiB if size <= 0). If interval > 0 the buffer is also flushed
+// periodically; call Close to stop the background flushing.
+func NewBufferedWriter(w io.Writer, size int, interval time.Duration) *BufferedWriter {
+	if size <= 0 {
+		size = 64 << 10
+	}
+	b := &BufferedWriter{
+		w:    w,
+		buf:  make([]byte, 0, size),
+		size: size,
+		done: make(chan struct{}),
+	}
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.loop(interval)
+	}
+	return b
+}
+
+// Write implements io.Writer.
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf)+len(p) > b.size {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) > b.size {
+		return b.w.Write(p)
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Flush writes the buffered data to the underlying writer.
+func (b *BufferedWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush()
+}
+
+// Close stops the periodic flushing and flushes the remaining data. It
+// returns the first error from a periodic flush or the final one, and
+// later calls return the same error. The underlying writer is not closed.
+func (b *BufferedWriter) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		b.wg.Wait()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.closeErr = errors.Join(b.err, b.flush())
+	})
+	return b.closeErr
+}
+
+func (b *BufferedWriter) flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.buf)
+	b.buf = b.buf[:0]
+	return err
+}
+
+func (b *BufferedWriter) loop(interval time.Duration) {
+	defer b.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-t.C:
+			b.mu.Lock()
+			if err := b.flush(); err != nil && b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
+	}
+}
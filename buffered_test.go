@@ -0,0 +1,45 @@
+package colorjson
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestBufferedWriterClose(t *testing.T) {
+	var out bytes.Buffer
+	b := NewBufferedWriter(&out, 0, time.Millisecond)
+	b.Write([]byte("a\n"))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if out.String() != "a\n" {
+		t.Errorf("got %q, want the buffered line", out.String())
+	}
+
+	b = NewBufferedWriter(failWriter{}, 0, time.Millisecond)
+	b.Write([]byte("a\n"))
+	time.Sleep(20 * time.Millisecond) // let a periodic flush fail
+	first := b.Close()
+	if first == nil {
+		t.Fatal("Close returned no error after a failed flush")
+	}
+	if err := b.Close(); err != first {
+		t.Errorf("second Close returned %v, want %v", err, first)
+	}
+}
@@ -0,0 +1,53 @@
+package colorjson
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// byteSize humanizes a if its key ends in one of h.ByteSizeSuffixes and it
+// holds an integer, appending the raw value as key+"_raw" too when
+// h.ByteSizeRaw is set.
+func (h *ColorJSONHandler) byteSize(attrs []slog.Attr, a slog.Attr) ([]slog.Attr, slog.Attr) {
+	var neg bool
+	var n uint64
+	switch a.Value.Kind() {
+	case slog.KindInt64:
+		i := a.Value.Int64()
+		neg, n = i < 0, uint64(i)
+		if neg {
+			n = -n
+		}
+	case slog.KindUint64:
+		n = a.Value.Uint64()
+	default:
+		return attrs, a
+	}
+	if !slices.ContainsFunc(h.ByteSizeSuffixes, func(s string) bool { return strings.HasSuffix(a.Key, s) }) {
+		return attrs, a
+	}
+	if h.ByteSizeRaw {
+		attrs = append(attrs, slog.Attr{Key: a.Key + "_raw", Value: a.Value})
+	}
+	s := humanBytes(n)
+	if neg {
+		s = "-" + s
+	}
+	return attrs, slog.String(a.Key, s)
+}
+
+// humanBytes formats n bytes with IEC units, e.g. "512 B" or "1.2 MiB".
+func humanBytes(n uint64) string {
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + " B"
+	}
+	const units = "KMGTPE"
+	f, i := float64(n)/1024, 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64) + " " + units[i:i+1] + "iB"
+}
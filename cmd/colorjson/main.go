@@ -0,0 +1,306 @@
+// Command colorjson reads JSON log lines on stdin, as written by slog, zap,
+// zerolog, pino and most other structured loggers, and writes them colorized
+// to stdout. Lines that are not JSON objects are passed through unchanged.
+//
+//	go run ./server 2>&1 | colorjson -format console
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	colorjson "github.com/hydronica/color-json"
+)
+
+var (
+	levelKeys = []string{"level", "lvl", "severity", "levelname"}
+	timeKeys  = []string{"time", "ts", "timestamp", "@timestamp"}
+	msgKeys   = []string{"msg", "message", "@message"}
+)
+
+func main() {
+	format := flag.String("format", "json", "output format: json, logfmt, console or indented")
+	minLevel := flag.String("level", "debug", "minimum level to show")
+	theme := flag.String("theme", "default", "color theme: "+strings.Join(colorjson.Themes(), ", ")+" or the path of a theme file")
+	flag.Parse()
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*minLevel)); err != nil {
+		fmt.Fprintln(os.Stderr, "colorjson:", err)
+		os.Exit(2)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	h := colorjson.NewHandler(out, &slog.HandlerOptions{Level: level})
+	colors, err := loadTheme(*theme)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	h.Colors = colors
+	switch *format {
+	case "json":
+		h.Format = colorjson.FormatJSON
+	case "logfmt":
+		h.Format = colorjson.FormatLogfmt
+	case "console":
+		h.Format = colorjson.FormatConsole
+	case "indented":
+		h.Format = colorjson.FormatIndented
+	default:
+		fmt.Fprintf(os.Stderr, "colorjson: unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	in := bufio.NewReaderSize(os.Stdin, 64<<10)
+	var line []byte
+	long := false // the line is over maxLine and passed through as read
+	for {
+		chunk, err := in.ReadSlice('\n')
+		if long {
+			out.Write(chunk)
+		} else if line = append(line, chunk...); len(line) > maxLine {
+			out.Write(line)
+			line, long = line[:0], true
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if !long && len(line) > 0 {
+			writeLine(h, out, bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r")))
+		}
+		line, long = line[:0], false
+		// flush per line so output keeps up with a live stream
+		out.Flush()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "colorjson:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// maxLine is the length of the longest line parsed as a record. Longer
+// lines are passed through unchanged rather than held in memory.
+const maxLine = 16 << 20
+
+// writeLine writes the record of a JSON log line to h, or else the line
+// itself to out.
+func writeLine(h *colorjson.ColorJSONHandler, out *bufio.Writer, line []byte) {
+	r, err := parseRecord(line)
+	if err != nil {
+		out.Write(line)
+		out.WriteByte('\n')
+	} else if h.Enabled(context.Background(), r.Level) {
+		h.Handle(context.Background(), r)
+	}
+}
+
+// loadTheme returns the registered theme name or else the theme file at
+// that path.
+func loadTheme(name string) (colorjson.Colors, error) {
+	if c, ok := colorjson.Theme(name); ok {
+		return c, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return colorjson.Colors{}, fmt.Errorf("colorjson: unknown theme %q", name)
+	}
+	defer f.Close()
+	return colorjson.LoadTheme(f)
+}
+
+// parseRecord converts a JSON log line into a slog.Record, taking the time,
+// level and message from their well-known keys. The remaining members are
+// added as attrs in their original order.
+func parseRecord(line []byte) (slog.Record, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return slog.Record{}, fmt.Errorf("not a JSON object")
+	}
+	attrs, err := parseObject(line)
+	if err != nil {
+		return slog.Record{}, err
+	}
+
+	var t time.Time
+	level := slog.LevelInfo
+	var msg string
+	rest := attrs[:0]
+	found := map[string]bool{}
+	for _, a := range attrs {
+		switch {
+		case !found["time"] && contains(timeKeys, a.Key):
+			if tt, ok := parseTime(a.Value); ok {
+				t, found["time"] = tt, true
+				continue
+			}
+		case !found["level"] && contains(levelKeys, a.Key):
+			if l, ok := parseLevel(a.Value); ok {
+				level, found["level"] = l, true
+				continue
+			}
+		case !found["msg"] && contains(msgKeys, a.Key) && a.Value.Kind() == slog.KindString:
+			msg, found["msg"] = a.Value.String(), true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	r := slog.NewRecord(t, level, msg, 0)
+	r.AddAttrs(rest...)
+	return r, nil
+}
+
+// parseObject parses a JSON object into attrs, keeping the order of its
+// members. Nested objects become groups.
+func parseObject(data []byte) ([]slog.Attr, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var attrs []slog.Attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", tok)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		v, err := parseValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: v})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// parseValue converts a raw JSON value into a slog.Value. Arrays are kept
+// as raw JSON.
+func parseValue(raw json.RawMessage) (slog.Value, error) {
+	switch raw[0] {
+	case '{':
+		attrs, err := parseObject(raw)
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return slog.GroupValue(attrs...), nil
+	case '[':
+		return slog.AnyValue(raw), nil
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return slog.StringValue(s), err
+	case 't', 'f':
+		return slog.BoolValue(raw[0] == 't'), nil
+	case 'n':
+		return slog.AnyValue(nil), nil
+	default:
+		if i, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return slog.Int64Value(i), nil
+		}
+		f, err := strconv.ParseFloat(string(raw), 64)
+		return slog.Float64Value(f), err
+	}
+}
+
+// parseTime accepts RFC 3339 strings and Unix timestamps, telling their
+// unit by magnitude: seconds (zap), milliseconds (pino, zerolog with
+// TimeFieldFormat = TimeFormatUnixMs), microseconds or nanoseconds.
+func parseTime(v slog.Value) (time.Time, bool) {
+	switch v.Kind() {
+	case slog.KindString:
+		t, err := time.Parse(time.RFC3339Nano, v.String())
+		return t, err == nil
+	case slog.KindInt64:
+		switch n := v.Int64(); {
+		case n > 1e18:
+			return time.Unix(0, n), true
+		case n > 1e15:
+			return time.UnixMicro(n), true
+		case n > 1e12:
+			return time.UnixMilli(n), true
+		default:
+			return time.Unix(n, 0), true
+		}
+	case slog.KindFloat64:
+		switch f := v.Float64(); {
+		case f > 1e18:
+			return time.Unix(0, int64(f)), true
+		case f > 1e15:
+			return time.Unix(0, int64(f*1e3)), true
+		case f > 1e12:
+			return time.Unix(0, int64(f*1e6)), true
+		default:
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseLevel accepts level names used by common loggers, case-insensitively,
+// and pino's numeric levels.
+func parseLevel(v slog.Value) (slog.Level, bool) {
+	if v.Kind() == slog.KindInt64 {
+		switch n := v.Int64(); {
+		case n >= 50:
+			return slog.LevelError, true
+		case n >= 40:
+			return slog.LevelWarn, true
+		case n >= 30:
+			return slog.LevelInfo, true
+		default:
+			return slog.LevelDebug, true
+		}
+	}
+	if v.Kind() != slog.KindString {
+		return 0, false
+	}
+	switch strings.ToLower(v.String()) {
+	case "trace", "debug":
+		return slog.LevelDebug, true
+	case "info", "information", "notice":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error", "err":
+		return slog.LevelError, true
+	case "fatal", "panic", "dpanic", "critical", "crit":
+		return slog.LevelError + 4, true
+	}
+	var l slog.Level
+	err := l.UnmarshalText([]byte(v.String()))
+	return l, err == nil
+}
+
+func contains(keys []string, k string) bool {
+	for _, key := range keys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package colorjson
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// ColorizeHandler colorizes the JSON lines written by another handler, such
+// as slog.JSONHandler, so its exact output is kept but gains color.
+type ColorizeHandler struct {
+	Colors       Colors       // allows for customizing colors
+	ColorProfile ColorProfile // colors the terminal supports, detected by NewColorizeHandler
+	ForceColor   bool         // write colors even with ProfileNone, set from COLORJSON_FORCE by NewColorizeHandler
+
+	inner slog.Handler
+	out   *colorizeOut // shared with the handlers derived with WithAttrs and WithGroup
+}
+
+// colorizeOut is the buffer a ColorizeHandler's inner handler writes to.
+type colorizeOut struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   io.Writer
+}
+
+// NewColorizeHandler returns a handler writing to w the colorized output of
+// the handler returned by inner, which must write one JSON object per line
+// to the writer it is given, e.g.
+//
+//	colorjson.NewColorizeHandler(os.Stderr, func(w io.Writer) slog.Handler {
+//		return slog.NewJSONHandler(w, nil)
+//	})
+func NewColorizeHandler(w io.Writer, inner func(io.Writer) slog.Handler) *ColorizeHandler {
+	out := &colorizeOut{w: w}
+	return &ColorizeHandler{
+		Colors:       envColors(),
+		ColorProfile: detectProfile(w),
+		ForceColor:   envForceColor(),
+		inner:        inner(&out.buf),
+		out:          out,
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *ColorizeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler. Lines that are not JSON objects are
+// written unchanged.
+func (h *ColorizeHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	var b []byte
+	for line := range bytes.Lines(h.out.buf.Bytes()) {
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 && line[0] == '{' {
+			b = colorizeJSON(b, line, h.Colors, 0, nil)
+		} else {
+			b = append(b, line...)
+		}
+		b = append(b, '\n')
+	}
+	_, err := h.out.w.Write(downgradeANSI(b, forcedProfile(h.ColorProfile, h.ForceColor)))
+	return err
+}
+
+// WithAttrs implements slog.Handler.
+func (h *ColorizeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.inner = h.inner.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *ColorizeHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.inner = h.inner.WithGroup(name)
+	return &h2
+}
@@ -0,0 +1,224 @@
+// Package colorjsontest provides a slog.Handler that captures records as
+// structured entries, and assertions on them, so applications can test
+// their logging without parsing colorized output.
+package colorjsontest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Entry is a captured record.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attrs holds the attrs of the record and those added with WithAttrs,
+	// resolved. Groups are nested maps and other values are those returned
+	// by slog.Value.Any, e.g. int64 for any signed integer.
+	Attrs map[string]any
+}
+
+// CaptureHandler is a slog.Handler keeping the records it handles in
+// memory. The handlers derived from it with WithAttrs and WithGroup share
+// its entries.
+type CaptureHandler struct {
+	Level slog.Leveler // minimum level captured, every level if nil
+
+	store *store
+	goas  []groupOrAttrs
+}
+
+type store struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// groupOrAttrs is a group or attrs added with WithGroup or WithAttrs.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewCaptureHandler returns an empty CaptureHandler capturing every level.
+func NewCaptureHandler() *CaptureHandler {
+	return &CaptureHandler{store: &store{}}
+}
+
+// Enabled implements slog.Handler.
+func (h *CaptureHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.Level == nil || level >= h.Level.Level()
+}
+
+// Handle implements slog.Handler.
+func (h *CaptureHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := map[string]any{}
+	m := attrs
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			sub := map[string]any{}
+			m[goa.group] = sub
+			m = sub
+			continue
+		}
+		addAttrs(m, goa.attrs)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(m, a)
+		return true
+	})
+	prune(attrs)
+
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.entries = append(h.store.entries, Entry{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   attrs,
+	})
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *CaptureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.goas = append(slices.Clip(h.goas), groupOrAttrs{attrs: attrs})
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *CaptureHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.goas = append(slices.Clip(h.goas), groupOrAttrs{group: name})
+	return &h2
+}
+
+// Entries returns the captured entries, oldest first.
+func (h *CaptureHandler) Entries() []Entry {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	return slices.Clone(h.store.entries)
+}
+
+// LastEntry returns the entry captured last. ok is false if there is none.
+func (h *CaptureHandler) LastEntry() (e Entry, ok bool) {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	if len(h.store.entries) == 0 {
+		return Entry{}, false
+	}
+	return h.store.entries[len(h.store.entries)-1], true
+}
+
+// Reset drops the captured entries.
+func (h *CaptureHandler) Reset() {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.entries = nil
+}
+
+// AssertLogged reports an error on t unless h captured a record with the
+// given level and message having the attrs in args, which are key/value
+// pairs and slog.Attrs as accepted by slog.Logger.Info. The record may have
+// other attrs too. Groups match if they contain the wanted attrs.
+func AssertLogged(t testing.TB, h *CaptureHandler, level slog.Level, msg string, args ...any) {
+	t.Helper()
+	r := slog.NewRecord(time.Time{}, level, msg, 0)
+	r.Add(args...)
+	want := map[string]any{}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(want, a)
+		return true
+	})
+	entries := h.Entries()
+	for _, e := range entries {
+		if e.Level == level && e.Message == msg && contains(e.Attrs, want) {
+			return
+		}
+	}
+	var logged strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&logged, "\n\t%s %q %v", e.Level, e.Message, e.Attrs)
+	}
+	if len(entries) == 0 {
+		logged.WriteString(" nothing")
+	}
+	t.Errorf("no %s record %q with %v was logged; logged:%s", level, msg, want, logged.String())
+}
+
+// addAttrs adds attrs to m.
+func addAttrs(m map[string]any, attrs []slog.Attr) {
+	for _, a := range attrs {
+		addAttr(m, a)
+	}
+}
+
+// addAttr adds a to m, resolved, following the slog handler rules: empty
+// attrs are ignored and groups with an empty key are inlined.
+func addAttr(m map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = a.Value.Any()
+		return
+	}
+	if a.Key == "" {
+		addAttrs(m, a.Value.Group())
+		return
+	}
+	sub, ok := m[a.Key].(map[string]any)
+	if !ok {
+		sub = map[string]any{}
+		m[a.Key] = sub
+	}
+	addAttrs(sub, a.Value.Group())
+}
+
+// prune removes the empty groups of m.
+func prune(m map[string]any) {
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			prune(sub)
+			if len(sub) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+// contains reports whether got has all the attrs of want.
+func contains(got, want map[string]any) bool {
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		wm, wok := w.(map[string]any)
+		gm, gok := g.(map[string]any)
+		switch {
+		case wok && gok:
+			if !contains(gm, wm) {
+				return false
+			}
+		case !reflect.DeepEqual(g, w):
+			return false
+		}
+	}
+	return true
+}
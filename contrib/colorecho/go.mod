@@ -0,0 +1,20 @@
+module github.com/hydronica/color-json/contrib/colorecho
+
+go 1.24.2
+
+require (
+	github.com/hydronica/color-json v0.0.0-20261017105352-edb77e0a5dc8
+	github.com/labstack/echo/v4 v4.15.1
+)
+
+require (
+	github.com/labstack/gommon v0.5.0 // indirect
+	github.com/mattn/go-colorable v0.1.15 // indirect
+	github.com/mattn/go-isatty v0.0.22 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.48.0 // indirect
+	golang.org/x/net v0.50.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+)
@@ -0,0 +1,77 @@
+// Package colorecho provides Echo middleware logging requests and recovered
+// panics through a slog.Logger, typically one writing to a
+// colorjson.ColorJSONHandler, in place of middleware.Logger and
+// middleware.Recover:
+//
+//	e := echo.New()
+//	e.Use(colorecho.Logger(logger), colorecho.Recover(logger))
+package colorecho
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	colorjson "github.com/hydronica/color-json"
+	"github.com/labstack/echo/v4"
+)
+
+// Logger returns middleware that logs every request as an "http" group like
+// colorjson.LogMiddleware, adding the matched route and the error returned
+// by the handler. Errors are passed to the HTTP error handler first so the
+// logged status is the one sent. slog.Default() is used when logger is nil.
+func Logger(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			status := colorjson.HTTPStatus(res.Status)
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+			}
+			if route := c.Path(); route != "" {
+				attrs = append(attrs, slog.String("route", route))
+			}
+			attrs = append(attrs,
+				slog.Any("status", status),
+				slog.Int64("bytes", res.Size),
+				slog.Duration("duration", time.Since(start)),
+			)
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			l.LogAttrs(req.Context(), status.Level, "http request", slog.Attr{Key: "http", Value: slog.GroupValue(attrs...)})
+			return err
+		}
+	}
+}
+
+// Recover returns middleware that recovers panics raised by the following
+// handlers, logs them with colorjson.RecoverAndLog and returns a 500
+// Internal Server Error to the HTTP error handler.
+func Recover(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			completed := false
+			defer func() {
+				if !completed {
+					err = echo.NewHTTPError(http.StatusInternalServerError)
+				}
+			}()
+			defer colorjson.RecoverAndLog(logger, false)
+			err = next(c)
+			completed = true
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package colorgin provides Gin middleware logging requests and recovered
+// panics through a slog.Logger, typically one writing to a
+// colorjson.ColorJSONHandler, in place of gin.Logger and gin.Recovery:
+//
+//	r := gin.New()
+//	r.Use(colorgin.Logger(logger), colorgin.Recovery(logger))
+package colorgin
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	colorjson "github.com/hydronica/color-json"
+)
+
+// Logger returns middleware that logs every request as an "http" group like
+// colorjson.LogMiddleware, adding the matched route and the errors attached
+// to the context. slog.Default() is used when logger is nil.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		start := time.Now()
+		c.Next()
+
+		status := colorjson.HTTPStatus(c.Writer.Status())
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+		}
+		if route := c.FullPath(); route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+		attrs = append(attrs,
+			slog.Any("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Duration("duration", time.Since(start)),
+		)
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate); len(errs) > 0 {
+			attrs = append(attrs, slog.String("error", strings.Join(errs.Errors(), "; ")))
+		}
+		l.LogAttrs(c.Request.Context(), status.Level, "http request", slog.Attr{Key: "http", Value: slog.GroupValue(attrs...)})
+	}
+}
+
+// Recovery returns middleware that recovers panics raised by the following
+// handlers, logs them with colorjson.RecoverAndLog and responds with 500
+// Internal Server Error.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		completed := false
+		defer func() {
+			if !completed {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		defer colorjson.RecoverAndLog(logger, false)
+		c.Next()
+		completed = true
+	}
+}
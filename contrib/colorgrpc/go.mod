@@ -0,0 +1,16 @@
+module github.com/hydronica/color-json/contrib/colorgrpc
+
+go 1.24.2
+
+require (
+	github.com/hydronica/color-json v0.0.0-20261017105352-edb77e0a5dc8
+	google.golang.org/grpc v1.80.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/net v0.50.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260120221211-b8f7ae30c516 // indirect
+)
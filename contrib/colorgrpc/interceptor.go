@@ -0,0 +1,155 @@
+// Package colorgrpc provides gRPC server interceptors logging every call
+// through a slog.Logger, typically one writing to a colorjson.ColorJSONHandler,
+// which shows the status code of the call in Colors.Status.
+package colorgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	colorjson "github.com/hydronica/color-json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Options configures the call logging.
+type Options struct {
+	Logger *slog.Logger // logger to write to, slog.Default() if nil
+
+	// Payloads adds the request and response messages of unary calls as
+	// "request" and "response", and logs every message of a stream at
+	// debug level. Payloads may hold sensitive data, so this is off by
+	// default.
+	Payloads bool
+}
+
+func (o *Options) logger() *slog.Logger {
+	if o == nil || o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
+
+func (o *Options) payloads() bool {
+	return o != nil && o.Payloads
+}
+
+// UnaryServerInterceptor returns an interceptor logging each unary call as a
+// "grpc" group holding the method, peer, status code and duration of the call.
+func UnaryServerInterceptor(opts *Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		attrs := callAttrs(ctx, info.FullMethod, start, err)
+		if opts.payloads() {
+			attrs = append(attrs, slog.Any("request", payload(req)))
+			if err == nil {
+				attrs = append(attrs, slog.Any("response", payload(resp)))
+			}
+		}
+		opts.logger().LogAttrs(ctx, codeLevel(status.Code(err)), "grpc call", slog.Attr{Key: "grpc", Value: slog.GroupValue(attrs...)})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor logging each streaming call
+// like UnaryServerInterceptor, with the number of messages sent and received.
+func StreamServerInterceptor(opts *Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		s := &serverStream{ServerStream: ss, method: info.FullMethod, opts: opts}
+		err := handler(srv, s)
+		ctx := ss.Context()
+		attrs := append(callAttrs(ctx, info.FullMethod, start, err),
+			slog.Int("sent", s.sent),
+			slog.Int("received", s.received),
+		)
+		opts.logger().LogAttrs(ctx, codeLevel(status.Code(err)), "grpc stream", slog.Attr{Key: "grpc", Value: slog.GroupValue(attrs...)})
+		return err
+	}
+}
+
+// serverStream counts, and optionally logs, the messages of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	method         string
+	opts           *Options
+	sent, received int
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.logMessage("sent", m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		s.logMessage("received", m)
+	}
+	return err
+}
+
+func (s *serverStream) logMessage(direction string, m any) {
+	if !s.opts.payloads() {
+		return
+	}
+	s.opts.logger().LogAttrs(s.Context(), slog.LevelDebug, "grpc stream message", slog.Group("grpc",
+		slog.String("method", s.method),
+		slog.String("direction", direction),
+		slog.Any("message", payload(m)),
+	))
+}
+
+// callAttrs returns the attrs common to unary and streaming calls.
+func callAttrs(ctx context.Context, method string, start time.Time, err error) []slog.Attr {
+	code := status.Code(err)
+	attrs := []slog.Attr{slog.String("method", method)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	attrs = append(attrs,
+		slog.Any("code", colorjson.StatusCode{Code: int(code), Name: code.String(), Level: codeLevel(code)}),
+		slog.Duration("duration", time.Since(start)),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	return attrs
+}
+
+// payload returns a protobuf message as JSON, other values as they are.
+func payload(m any) any {
+	if pm, ok := m.(proto.Message); ok {
+		if b, err := protojson.Marshal(pm); err == nil {
+			return json.RawMessage(b)
+		}
+	}
+	return m
+}
+
+// codeLevel maps a gRPC status code to the level the call is logged at:
+// errors caused by the client are warnings, those of the server errors.
+func codeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
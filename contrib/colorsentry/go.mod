@@ -0,0 +1,10 @@
+module github.com/hydronica/color-json/contrib/colorsentry
+
+go 1.24.2
+
+require github.com/getsentry/sentry-go v0.45.1
+
+require (
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+)
@@ -0,0 +1,160 @@
+// Package colorsentry forwards error records to Sentry while passing every
+// record on to another slog.Handler, typically a colorjson.ColorJSONHandler
+// writing to the terminal.
+package colorsentry
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Options configures the Sentry forwarding.
+type Options struct {
+	DSN          string        // Sentry DSN to send events to
+	Level        slog.Leveler  // minimum level sent to Sentry, slog.LevelError if nil
+	FlushTimeout time.Duration // maximum time Flush waits for pending events, 2s if zero
+	Environment  string        // optional environment reported with each event
+	Release      string        // optional release reported with each event
+}
+
+// Handler sends records at or above Options.Level to Sentry and passes all
+// records to the next handler.
+type Handler struct {
+	next   slog.Handler
+	hub    *sentry.Hub
+	opts   Options
+	attrs  []slog.Attr // attrs from WithAttrs, keys prefixed with their groups
+	groups []string    // groups from WithGroup
+}
+
+// New creates a Handler that wraps next and reports to the Sentry project
+// identified by opts.DSN.
+func New(next slog.Handler, opts Options) (*Handler, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              opts.DSN,
+		AttachStacktrace: true,
+		Environment:      opts.Environment,
+		Release:          opts.Release,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelError
+	}
+	if opts.FlushTimeout == 0 {
+		opts.FlushTimeout = 2 * time.Second
+	}
+	return &Handler{
+		next: next,
+		hub:  sentry.NewHub(client, sentry.NewScope()),
+		opts: opts,
+	}, nil
+}
+
+// Flush waits until pending events are sent or the flush timeout expires.
+// It reports whether all events were sent.
+func (h *Handler) Flush() bool {
+	return h.hub.Flush(h.opts.FlushTimeout)
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level() || h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.opts.Level.Level() {
+		h.capture(r)
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	h2.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		h2.attrs = appendFlat(h2.attrs, h.groups, a)
+	}
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.groups = append(slices.Clip(h.groups), name)
+	return &h2
+}
+
+// capture sends the record to Sentry. The record's attrs are reported as
+// the "attributes" context and the first error attr becomes the exception.
+func (h *Handler) capture(r slog.Record) {
+	attrs := slices.Clip(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendFlat(attrs, h.groups, a)
+		return true
+	})
+
+	event := sentry.NewEvent()
+	event.Level = sentryLevel(r.Level)
+	event.Message = r.Message
+	event.Timestamp = r.Time
+	extra := make(sentry.Context, len(attrs))
+	for _, a := range attrs {
+		if err, ok := a.Value.Any().(error); ok && event.Exception == nil {
+			event.SetException(err, 10)
+		}
+		extra[a.Key] = a.Value.Any()
+	}
+	if len(extra) > 0 {
+		event.Contexts["attributes"] = extra
+	}
+	h.hub.CaptureEvent(event)
+}
+
+// appendFlat appends a to attrs, flattening groups into dotted keys.
+func appendFlat(attrs []slog.Attr, groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendFlat(attrs, groups, ga)
+		}
+		return attrs
+	}
+	if a.Key == "" {
+		return attrs
+	}
+	a.Key = strings.Join(append(slices.Clip(groups), a.Key), ".")
+	return append(attrs, a)
+}
+
+// sentryLevel maps a slog level to a Sentry level.
+func sentryLevel(l slog.Level) sentry.Level {
+	switch {
+	case l >= slog.LevelError+4:
+		return sentry.LevelFatal
+	case l >= slog.LevelError:
+		return sentry.LevelError
+	case l >= slog.LevelWarn:
+		return sentry.LevelWarning
+	case l >= slog.LevelInfo:
+		return sentry.LevelInfo
+	default:
+		return sentry.LevelDebug
+	}
+}
@@ -0,0 +1,127 @@
+// Package colorzap provides a zapcore.Core writing through a slog.Handler,
+// typically a colorjson.ColorJSONHandler, so zap and slog loggers in the same
+// program produce identical colored output.
+package colorzap
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Core is a zapcore.Core passing entries to a slog.Handler as records.
+// Fields become attrs; zap.Namespace fields become groups.
+type Core struct {
+	h slog.Handler
+}
+
+// NewCore returns a Core writing to h, e.g.
+//
+//	logger := zap.New(colorzap.NewCore(colorjson.NewHandler(os.Stderr, nil)), zap.AddCaller())
+func NewCore(h slog.Handler) *Core {
+	return &Core{h: h}
+}
+
+// Enabled implements zapcore.LevelEnabler.
+func (c *Core) Enabled(l zapcore.Level) bool {
+	return c.h.Enabled(context.Background(), Level(l))
+}
+
+// With implements zapcore.Core.
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	h := c.h
+	for i, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			// the fields after a namespace are nested in it
+			h = h.WithAttrs(Attrs(fields[:i])).WithGroup(f.Key)
+			return (&Core{h: h}).With(fields[i+1:])
+		}
+	}
+	if len(fields) > 0 {
+		h = h.WithAttrs(Attrs(fields))
+	}
+	return &Core{h: h}
+}
+
+// Check implements zapcore.Core.
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core. The logger name and stack trace of the
+// entry are added as "logger" and "stack" attrs.
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var pc uintptr
+	if ent.Caller.Defined {
+		pc = ent.Caller.PC
+	}
+	r := slog.NewRecord(ent.Time, Level(ent.Level), ent.Message, pc)
+	if ent.LoggerName != "" {
+		r.AddAttrs(slog.String("logger", ent.LoggerName))
+	}
+	r.AddAttrs(Attrs(fields)...)
+	if ent.Stack != "" {
+		r.AddAttrs(slog.String("stack", ent.Stack))
+	}
+	return c.h.Handle(context.Background(), r)
+}
+
+// Sync implements zapcore.Core. Records are written by the handler as they
+// are handled, so there is nothing to flush.
+func (c *Core) Sync() error {
+	return nil
+}
+
+// Level converts a zap level to a slog level. DPanic, Panic and Fatal map
+// to slog.LevelError+4.
+func Level(l zapcore.Level) slog.Level {
+	switch {
+	case l <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case l == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case l == zapcore.WarnLevel:
+		return slog.LevelWarn
+	case l == zapcore.ErrorLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// Attrs converts zap fields to slog attrs, in order. The fields following a
+// zap.Namespace field are nested in a group.
+func Attrs(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.SkipType:
+		case zapcore.NamespaceType:
+			return append(attrs, slog.Attr{Key: f.Key, Value: slog.GroupValue(Attrs(fields[i+1:])...)})
+		case zapcore.ErrorType:
+			attrs = append(attrs, slog.Any(f.Key, f.Interface))
+		case zapcore.StringerType:
+			attrs = append(attrs, slog.Any(f.Key, f.Interface))
+		default:
+			// zap's own encoding gives the field's Go value, e.g. a
+			// time.Duration or a map for an ObjectMarshaler
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			if v, ok := enc.Fields[f.Key]; ok && len(enc.Fields) == 1 {
+				attrs = append(attrs, slog.Any(f.Key, v))
+				continue
+			}
+			// zap.Inline adds several keys
+			for _, k := range slices.Sorted(maps.Keys(enc.Fields)) {
+				attrs = append(attrs, slog.Any(k, enc.Fields[k]))
+			}
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,7 @@
+module github.com/hydronica/color-json/contrib/colorzap
+
+go 1.24.2
+
+require go.uber.org/zap v1.28.0
+
+require go.uber.org/multierr v1.10.0 // indirect
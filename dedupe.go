@@ -0,0 +1,64 @@
+package colorjson
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+// DuplicateKeys selects what a handler does with attrs that have the same
+// key in the same group, e.g. "user" added with WithAttrs and again in the
+// record. Groups with the same key are merged under all policies except
+// DuplicatesAllow.
+type DuplicateKeys int
+
+const (
+	DuplicatesAllow    DuplicateKeys = iota // write every attr, like slog.JSONHandler (default)
+	DuplicatesKeepLast                      // write only the last attr with a key
+	DuplicatesRename                        // rename the earlier attrs to key_1, key_2, ...
+)
+
+// dedupe returns attrs with duplicate keys resolved by policy d, merging
+// groups with the same key. attrs is not modified.
+func dedupe(attrs []slog.Attr, d DuplicateKeys) []slog.Attr {
+	if d == DuplicatesAllow {
+		return attrs
+	}
+	last := make(map[string]int, len(attrs))
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if i, ok := last[a.Key]; ok && a.Value.Kind() == slog.KindGroup && out[i].Value.Kind() == slog.KindGroup {
+			out[i].Value = slog.GroupValue(append(slices.Clip(out[i].Value.Group()), a.Value.Group()...)...)
+			continue
+		}
+		last[a.Key] = len(out)
+		out = append(out, a)
+	}
+	var renamed map[string]int
+	kept := out[:0]
+	for i, a := range out {
+		if a.Value.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(dedupe(a.Value.Group(), d)...)
+		}
+		if last[a.Key] != i {
+			if d == DuplicatesKeepLast {
+				continue
+			}
+			if renamed == nil {
+				renamed = make(map[string]int)
+			}
+			// skip names taken by other attrs or earlier renames
+			key := a.Key
+			for {
+				renamed[key]++
+				a.Key = key + "_" + strconv.Itoa(renamed[key])
+				if _, taken := last[a.Key]; !taken {
+					break
+				}
+			}
+			last[a.Key] = -1
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
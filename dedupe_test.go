@@ -0,0 +1,38 @@
+package colorjson
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	matchesJSONHandler(t, func(l *slog.Logger) {
+		l.With("a", 1).Info("m", "a", 2, slog.Group("g", "x", 1), slog.Group("g", "x", 2))
+	})
+
+	for _, tt := range []struct {
+		policy DuplicateKeys
+		log    func(*slog.Logger)
+		want   string
+	}{
+		{DuplicatesKeepLast, func(l *slog.Logger) { l.With("a", 1).Info("m", "a", 2) }, `"a":2`},
+		{DuplicatesKeepLast, func(l *slog.Logger) { l.Info("m", slog.Group("g", "x", 1, "y", 1), slog.Group("g", "x", 2)) }, `"g":{"y":1,"x":2}`},
+		{DuplicatesRename, func(l *slog.Logger) { l.With("a", 1).Info("m", "a", 2, "a", 3) }, `"a_1":1,"a_2":2,"a":3`},
+		{DuplicatesRename, func(l *slog.Logger) { l.Info("m", "a", 1, "a_1", 2, "a", 3) }, `"a_2":1,"a_1":2,"a":3`},
+		{DuplicatesRename, func(l *slog.Logger) { l.Info("m", "a", 1, "a", 2, "a_1", 3, "a", 4) }, `"a_2":1,"a_3":2,"a_1":3,"a":4`},
+		{DuplicatesRename, func(l *slog.Logger) { l.Info("m", slog.Group("g", "x", 1), "g", 2) }, `"g_1":{"x":1},"g":2`},
+		{DuplicatesRename, func(l *slog.Logger) { l.WithGroup("g").Info("m", "x", 1, "x", 2) }, `"g":{"x_1":1,"x":2}`},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime})
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		h.DuplicateKeys = tt.policy
+		tt.log(slog.New(h))
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if buf.String() != want {
+			t.Errorf("policy %d: got %s want %s", tt.policy, strings.TrimSpace(buf.String()), want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package colorjson
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxDiffMessages bounds the number of messages DiffPrevious remembers the
+// values of; past it they are forgotten and compared afresh.
+const maxDiffMessages = 1024
+
+// diffState holds the values of the last record of each message, for
+// DiffPrevious. It is shared by the handlers derived with WithAttrs and
+// WithGroup.
+type diffState struct {
+	mu   sync.Mutex
+	prev map[string]map[string]string // message -> key path -> written value
+}
+
+// previous returns the values of the last record with message msg, nil if
+// there is none. The map is never changed once stored.
+func (d *diffState) previous(msg string) map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.prev[msg]
+}
+
+// store records the values of the record with message msg.
+func (d *diffState) store(msg string, values map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.prev == nil || len(d.prev) >= maxDiffMessages {
+		d.prev = make(map[string]map[string]string)
+	}
+	d.prev[msg] = values
+}
+
+// diffValue repaints the value written to e.buf from start on in
+// Colors.Changed if it differs from the previous record's, or in
+// Colors.Dim if it does not. key is the attr's key.
+func (e *encoder) diffValue(key string, start int) {
+	path := key
+	if len(e.path) > 0 {
+		path = strings.Join(e.path, ".") + "." + key
+	}
+	plain := stripANSI(e.buf[start:])
+	e.diffCur[path] = string(plain)
+	if e.diffPrev == nil {
+		return
+	}
+	c := e.colors.Dim
+	if prev, ok := e.diffPrev[path]; !ok || prev != string(plain) {
+		c = e.colors.Changed
+	}
+	e.buf = e.buf[:start]
+	e.colored(c, plain)
+}
@@ -0,0 +1,107 @@
+package colorjson
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// EMFOptions declares the CloudWatch metrics extracted from records written
+// in CloudWatch Embedded Metric Format.
+type EMFOptions struct {
+	Namespace  string      // CloudWatch namespace of the metrics
+	Dimensions []string    // top-level attr keys used as dimensions
+	Metrics    []EMFMetric // top-level attr keys reported as metrics
+}
+
+// EMFMetric declares a top-level attr as a CloudWatch metric.
+type EMFMetric struct {
+	Name string `json:"Name"`           // attr key holding the metric value
+	Unit string `json:"Unit,omitempty"` // CloudWatch unit, e.g. "Milliseconds"
+}
+
+// NewCloudWatchHandler returns a handler for services that may run on AWS.
+// On AWS (see OnAWS) it writes uncolored JSON with an Embedded Metric Format
+// "_aws" member, so CloudWatch extracts the metrics declared in emf from the
+// log stream. Elsewhere it is a regular colorized handler.
+// Metrics and dimensions must be top-level attrs. Duration metrics are
+// written in milliseconds, with the unit "Milliseconds" unless declared.
+func NewCloudWatchHandler(w io.Writer, opts *slog.HandlerOptions, emf EMFOptions) *ColorJSONHandler {
+	h := NewHandler(w, opts)
+	if OnAWS() {
+		h.Colors = Colors{}
+		h.Highlights = nil
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		h.emf = &emf
+	}
+	return h
+}
+
+// OnAWS reports whether the process runs on AWS Lambda, ECS or another
+// environment that sets AWS_EXECUTION_ENV.
+func OnAWS() bool {
+	for _, env := range []string{
+		"AWS_LAMBDA_FUNCTION_NAME",
+		"ECS_CONTAINER_METADATA_URI_V4",
+		"ECS_CONTAINER_METADATA_URI",
+		"AWS_EXECUTION_ENV",
+	} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []EMFMetric `json:"Metrics"`
+}
+
+// metadata returns the "_aws" attr for a record with the given top-level
+// attrs. Only metrics and dimensions present in attrs are declared; ok is
+// false when the record holds none of the metrics. Duration metrics in
+// attrs are replaced by their value in milliseconds, as CloudWatch takes
+// only numbers.
+func (o *EMFOptions) metadata(t time.Time, attrs []slog.Attr) (slog.Attr, bool) {
+	present := make(map[string]int, len(attrs))
+	for i, a := range attrs {
+		present[a.Key] = i + 1
+	}
+	d := emfDirective{Namespace: o.Namespace, Dimensions: [][]string{{}}}
+	for _, m := range o.Metrics {
+		i := present[m.Name]
+		if i == 0 {
+			continue
+		}
+		if a := &attrs[i-1]; a.Value.Kind() == slog.KindDuration {
+			a.Value = slog.Float64Value(float64(a.Value.Duration()) / float64(time.Millisecond))
+			if m.Unit == "" {
+				m.Unit = "Milliseconds"
+			}
+		}
+		d.Metrics = append(d.Metrics, m)
+	}
+	if len(d.Metrics) == 0 {
+		return slog.Attr{}, false
+	}
+	for _, dim := range o.Dimensions {
+		if present[dim] > 0 {
+			d.Dimensions[0] = append(d.Dimensions[0], dim)
+		}
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return slog.Any("_aws", emfMetadata{
+		Timestamp:         t.UnixMilli(),
+		CloudWatchMetrics: []emfDirective{d},
+	}), true
+}
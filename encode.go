@@ -0,0 +1,1122 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// encoder builds the colorized output for a single record
+type encoder struct {
+	buf      []byte
+	colors   Colors
+	format   Format
+	empty    bool      // true when no member has been written to the current object
+	prefix   []string  // enclosing groups, when groups are flattened into keys
+	sep      string    // separator of the groups in flattened keys
+	flatten  bool      // flatten groups into keys in FormatJSON too
+	link     string    // OSC 8 hyperlink target for the source being written, if any
+	time     string    // layout of the built-in time, see ColorJSONHandler.TimeFormat
+	attrTime string    // layout of time values, see ColorJSONHandler.AttrTimeFormat
+	since    time.Time // reference time for the elapsed time formats
+
+	durations     DurationFormat // format of durations
+	bytes         BytesFormat    // format of []byte values
+	bytesPreview  int            // bytes shown by BytesPreview
+	stringerTypes bool           // add the concrete type to fmt.Stringer values
+	errorChain    bool           // add the errors wrapped by error values
+	maxDepth      int            // nesting limit of encoded Go values, unlimited if zero
+	maxValue      int            // size limit of values, unlimited if zero
+	flushAt       int            // size at which the record so far is flushed, see StreamThreshold
+	eol           string         // record terminator
+	flush         func([]byte)
+	highlights    []Highlight // rules for the message
+	grep          bool        // some highlights also apply to keys and values
+	escape        jsonEscape  // characters escaped beyond those the format requires
+	int64String   bool        // quote integers JavaScript cannot represent exactly
+	floatPrec     int         // digits after the decimal point of floats, shortest if zero
+	nonFiniteStr  bool        // write NaN and ±Inf as strings rather than null in JSON
+	depth         int         // number of open JSON objects and arrays
+	plain         bool        // no colors are written, see ColorJSONHandler.ColorProfile
+
+	// DiffPrevious state: the values of the previous record with the same
+	// message, nil if there is none, and those of this record
+	diffPrev map[string]string
+	diffCur  map[string]string
+
+	// the rules coloring values by their value, and the groups open
+	// around the current attr, tracked for them and DiffPrevious
+	valueRules []ValueRule
+	thresholds map[string]float64
+	trackPath  bool
+	path       []string
+
+	// AlignColumns state: the widths of the columns, nil when not aligning,
+	// and the spaces due before the next column
+	align    *alignState
+	msgWidth int
+	pad      int
+}
+
+// colored appends s wrapped in color c. No escape codes are written when c is empty.
+func (e *encoder) colored(c TerminalColor, s []byte) {
+	e.appendColor(c)
+	e.buf = append(e.buf, s...)
+	e.reset(c)
+}
+
+// appendColor starts text in color c, which is ended by reset.
+func (e *encoder) appendColor(c TerminalColor) {
+	e.buf = append(e.buf, c...)
+}
+
+// reset ends text written in color c, started by appending c to e.buf.
+func (e *encoder) reset(c TerminalColor) {
+	if c != "" {
+		e.buf = append(e.buf, Reset...)
+	}
+}
+
+// coloredString appends s as a quoted JSON string wrapped in color c.
+func (e *encoder) coloredString(c TerminalColor, s string) {
+	if e.grep {
+		e.colored(c, highlight(appendJSONString(nil, s, e.escape), c, e.highlights, true))
+		return
+	}
+	e.appendColor(c)
+	if e.streaming() && len(s) > e.flushAt {
+		e.streamJSONString(s)
+	} else {
+		e.buf = appendJSONString(e.buf, s, e.escape)
+	}
+	e.reset(c)
+}
+
+// streamJSONString appends s as a JSON string in parts of about flushAt
+// bytes, flushing each, so a large string is not held in e.buf whole.
+func (e *encoder) streamJSONString(s string) {
+	e.quote()
+	for len(s) > 0 {
+		cut := min(e.flushAt, len(s))
+		for cut < len(s) && cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = min(e.flushAt, len(s))
+		}
+		e.buf = appendJSONStringContent(e.buf, s[:cut], e.escape)
+		s = s[cut:]
+		e.flushPart()
+	}
+	e.quote()
+}
+
+// hyperlink appends the output of write as an OSC 8 hyperlink to e.link,
+// which terminals that support it make clickable.
+func (e *encoder) hyperlink(write func()) {
+	if e.link == "" {
+		write()
+		return
+	}
+	e.buf = append(e.buf, "\033]8;;"...)
+	e.buf = append(e.buf, e.link...)
+	e.buf = append(e.buf, "\033\\"...)
+	write()
+	e.buf = append(e.buf, "\033]8;;\033\\"...)
+}
+
+// flat reports whether the format writes key=value pairs rather than JSON.
+func (e *encoder) flat() bool {
+	return e.format == FormatLogfmt || e.format == FormatConsole
+}
+
+// flatGroups reports whether groups are written as key prefixes rather
+// than nested objects.
+func (e *encoder) flatGroups() bool {
+	return e.flatten || e.flat()
+}
+
+// appendString appends a string value in color c, quoted as required by the format.
+func (e *encoder) appendString(c TerminalColor, s string) {
+	if e.flat() {
+		if e.grep {
+			e.colored(c, highlight(appendLogfmtString(nil, s, e.escape), c, e.highlights, true))
+			return
+		}
+		e.appendColor(c)
+		e.buf = appendLogfmtString(e.buf, s, e.escape)
+		e.reset(c)
+		return
+	}
+	e.coloredString(c, s)
+}
+
+// beginRecord starts a new record.
+func (e *encoder) beginRecord() {
+	if e.flat() {
+		e.empty = true
+		return
+	}
+	e.openBrace('{')
+}
+
+// endRecord terminates the record.
+func (e *encoder) endRecord() {
+	e.pad = 0
+	if !e.flat() {
+		e.closeBrace('}')
+	}
+	e.buf = append(e.buf, e.eol...)
+}
+
+func (e *encoder) openBrace(b byte) {
+	e.colored(e.colors.braceColor(e.depth), []byte{b})
+	e.depth++
+	e.empty = true
+}
+
+func (e *encoder) closeBrace(b byte) {
+	e.depth--
+	e.colored(e.colors.braceColor(e.depth), []byte{b})
+	e.empty = false
+}
+
+// key writes the separator (if needed) and the key of the next object member.
+func (e *encoder) key(k string) {
+	if e.flat() {
+		if !e.empty {
+			e.buf = append(e.buf, ' ')
+		}
+		e.empty = false
+		e.padColumn()
+		// dimmed keys keep the focus on the values in FormatConsole
+		c := e.colors.Key
+		if e.format == FormatConsole {
+			c = e.colors.Dim
+		}
+		e.appendColor(c)
+		if e.grep {
+			e.buf = append(e.buf, highlight(e.appendFlatKey(nil, k), c, e.highlights, true)...)
+		} else {
+			e.buf = e.appendFlatKey(e.buf, k)
+		}
+		if e.format == FormatConsole {
+			e.buf = append(e.buf, '=')
+			e.reset(c)
+		} else {
+			e.reset(c)
+			e.buf = append(e.buf, '=')
+		}
+		return
+	}
+	if !e.empty {
+		e.punct(',')
+	}
+	e.empty = false
+	e.padColumn()
+	if len(e.prefix) > 0 {
+		var scratch [64]byte
+		b := scratch[:0]
+		for _, p := range e.prefix {
+			b = append(append(b, p...), e.sep...)
+		}
+		k = string(append(b, k...))
+	}
+	e.coloredString(e.colors.depthColor(e.colors.Key, e.depth-1), k)
+	e.punct(':')
+}
+
+// appendFlatKey appends k prefixed with the enclosing groups, joined by
+// e.sep, quoting the result if needed.
+func (e *encoder) appendFlatKey(b []byte, k string) []byte {
+	quote := e.needsQuoting(k) || k == ""
+	for _, p := range e.prefix {
+		quote = quote || e.needsQuoting(p)
+	}
+	if quote {
+		if len(e.prefix) > 0 {
+			k = strings.Join(e.prefix, e.sep) + e.sep + k
+		}
+		return appendLogfmtString(b, k, e.escape)
+	}
+	for _, p := range e.prefix {
+		b = append(append(b, p...), e.sep...)
+	}
+	return append(b, k...)
+}
+
+// punct appends a comma or colon in the punctuation color.
+func (e *encoder) punct(b byte) {
+	e.colored(e.colors.punctuation(), []byte{b})
+}
+
+// builtin writes one of the record's built-in attributes (time, level,
+// source, msg) after ReplaceAttr has been applied.
+func (e *encoder) builtin(a slog.Attr) {
+	if a.Key == "" {
+		return
+	}
+	if e.align != nil {
+		e.alignedBuiltin(a)
+		return
+	}
+	e.writeBuiltin(a)
+}
+
+// writeBuiltin writes a built-in attribute.
+func (e *encoder) writeBuiltin(a slog.Attr) {
+	if e.format == FormatConsole && e.consoleBuiltin(a) {
+		return
+	}
+	if a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+		layout := e.time
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		e.key(a.Key)
+		e.timeValue(a.Value.Time(), layout)
+		return
+	}
+	if a.Key == slog.MessageKey && a.Value.Kind() == slog.KindString && len(e.highlights) > 0 {
+		e.key(a.Key)
+		var b []byte
+		if e.flat() {
+			b = appendLogfmtString(nil, a.Value.String(), e.escape)
+		} else {
+			b = appendJSONString(nil, a.Value.String(), e.escape)
+		}
+		e.colored(e.colors.String, highlight(b, e.colors.String, e.highlights, false))
+		return
+	}
+	if a.Key == slog.LevelKey {
+		if c, ok := e.levelColor(a.Value); ok {
+			e.key(a.Key)
+			if l, ok := a.Value.Any().(slog.Level); ok {
+				e.appendString(c, l.String())
+			} else {
+				e.appendString(c, a.Value.String())
+			}
+			return
+		}
+	}
+	e.attr(a)
+}
+
+// consoleBuiltin writes a built-in attribute without its key, as in
+// "15:04:05 INF message key=value". It reports false if a was changed by
+// ReplaceAttr into something that must be written as a regular attribute.
+func (e *encoder) consoleBuiltin(a slog.Attr) bool {
+	var scratch [64]byte
+	var b []byte
+	var c TerminalColor
+	switch {
+	case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime:
+		layout := e.time
+		if layout == "" {
+			layout = time.TimeOnly
+		}
+		b, _ = appendTime(scratch[:0], a.Value.Time(), layout, e.since)
+		c = e.colors.Dim
+	case a.Key == slog.LevelKey:
+		l, ok := a.Value.Any().(slog.Level)
+		if !ok {
+			return false
+		}
+		c, _ = e.levelColor(a.Value)
+		b = appendLevelBadge(scratch[:0], l)
+	case a.Key == slog.SourceKey && a.Value.Kind() == slog.KindString:
+		b, c = []byte(a.Value.String()), e.colors.Dim
+	case a.Key == slog.SourceKey:
+		src, ok := a.Value.Any().(*slog.Source)
+		if !ok {
+			return false
+		}
+		b, c = strconv.AppendInt(append(scratch[:0], src.File+":"...), int64(src.Line), 10), e.colors.Dim
+	case a.Key == slog.MessageKey && a.Value.Kind() == slog.KindString:
+		msg := a.Value.String()
+		if msg == "" {
+			return true
+		}
+		if !e.empty {
+			e.buf = append(e.buf, ' ')
+		}
+		e.empty = false
+		e.padColumn()
+		if e.escape&escapeUnicode != 0 && !isASCII(msg) {
+			msg = string(escapeNonASCII([]byte(msg)))
+		}
+		if len(e.highlights) > 0 {
+			e.buf = append(e.buf, highlight([]byte(msg), "", e.highlights, false)...)
+		} else {
+			e.buf = append(e.buf, msg...)
+		}
+		return true
+	default:
+		return false
+	}
+	if !e.empty {
+		e.buf = append(e.buf, ' ')
+	}
+	e.empty = false
+	e.padColumn()
+	e.hyperlink(func() { e.colored(c, b) })
+	return true
+}
+
+// appendLevelBadge appends the three letter abbreviation of a level, e.g.
+// "INF" or "ERR+2".
+func appendLevelBadge(b []byte, l slog.Level) []byte {
+	name, delta := "DBG", l-slog.LevelDebug
+	switch {
+	case l >= slog.LevelError:
+		name, delta = "ERR", l-slog.LevelError
+	case l >= slog.LevelWarn:
+		name, delta = "WRN", l-slog.LevelWarn
+	case l >= slog.LevelInfo:
+		name, delta = "INF", l-slog.LevelInfo
+	}
+	b = append(b, name...)
+	if delta > 0 {
+		b = append(b, '+')
+	}
+	if delta != 0 {
+		b = strconv.AppendInt(b, int64(delta), 10)
+	}
+	return b
+}
+
+// levelColor reports the color for a level value, either a slog.Level or one
+// of the standard level names.
+func (e *encoder) levelColor(v slog.Value) (TerminalColor, bool) {
+	var l slog.Level
+	switch v.Kind() {
+	case slog.KindAny:
+		lv, ok := v.Any().(slog.Level)
+		if !ok {
+			return "", false
+		}
+		l = lv
+	case slog.KindString:
+		if !isLogLevel(v.String()) {
+			return "", false
+		}
+		if err := l.UnmarshalText([]byte(v.String())); err != nil {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	switch {
+	case l >= slog.LevelError:
+		return e.colors.LevelError, true
+	case l >= slog.LevelWarn:
+		return e.colors.LevelWarn, true
+	case l >= slog.LevelInfo:
+		return e.colors.LevelInfo, true
+	default:
+		return e.colors.LevelDebug, true
+	}
+}
+
+// statusColor returns the color of a StatusCode: Colors.Status, or the
+// level color from WARN up.
+func (e *encoder) statusColor(s StatusCode) TerminalColor {
+	if s.Level >= slog.LevelWarn {
+		c, _ := e.levelColor(slog.AnyValue(s.Level))
+		return c
+	}
+	return e.colors.status()
+}
+
+// openGroup starts a group of attrs, a nested object in JSON or a key
+// prefix in the flat formats.
+func (e *encoder) openGroup(name string) {
+	if e.trackPath {
+		e.path = append(e.path, name)
+	}
+	if e.flatGroups() {
+		e.prefix = append(e.prefix, name)
+		return
+	}
+	e.key(name)
+	e.openBrace('{')
+}
+
+// closeGroup ends the group opened last.
+func (e *encoder) closeGroup() {
+	if e.trackPath {
+		e.path = e.path[:len(e.path)-1]
+	}
+	if e.flatGroups() {
+		e.prefix = e.prefix[:len(e.prefix)-1]
+		return
+	}
+	e.closeBrace('}')
+}
+
+// attr writes a key/value member of the current object.
+func (e *encoder) attr(a slog.Attr) {
+	if a.Value.Kind() == slog.KindAny {
+		if v, ok := formatValue(a.Value.Any()); ok {
+			a.Value = v
+		}
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		e.openGroup(a.Key)
+		for _, ga := range attrs {
+			e.attr(ga)
+		}
+		e.closeGroup()
+		return
+	}
+	e.key(a.Key)
+	start := len(e.buf)
+	e.value(a.Value)
+	if e.diffCur != nil {
+		e.diffValue(a.Key, start)
+	}
+	if len(e.valueRules) > 0 || len(e.thresholds) > 0 {
+		if c, ok := ruleColor(e.valueRules, e.path, a.Key, a.Value); ok {
+			e.paintValue(c, start)
+		} else if overThreshold(e.thresholds, e.path, a.Key, a.Value) {
+			e.paintValue(e.colors.threshold(), start)
+		}
+	}
+	e.flushPart()
+}
+
+// flushPart writes the record encoded so far to the output once it
+// reaches flushAt bytes, see StreamThreshold.
+func (e *encoder) flushPart() {
+	if e.flush != nil && len(e.buf) >= e.flushAt {
+		e.flush(e.buf)
+		e.buf = e.buf[:0]
+	}
+}
+
+// streaming reports whether values may be flushed while they are
+// encoded. Value rules, thresholds and diffs repaint a value once it is
+// written, so it must stay whole in e.buf.
+func (e *encoder) streaming() bool {
+	return e.flush != nil && !e.trackPath
+}
+
+// value writes a non-group value.
+func (e *encoder) value(v slog.Value) {
+	switch v.Kind() {
+	case slog.KindString:
+		e.appendString(e.colors.String, capValue(v.String(), e.maxValue))
+	case slog.KindInt64:
+		if n := v.Int64(); e.int64String && (n > maxSafeInt || n < -maxSafeInt) {
+			e.appendColor(e.colors.Number)
+			e.quote()
+			e.buf = strconv.AppendInt(e.buf, n, 10)
+			e.quote()
+			e.reset(e.colors.Number)
+		} else {
+			e.appendInt(e.colors.Number, n)
+		}
+	case slog.KindUint64:
+		// beyond 2^53 quoted in JSON, see ColorJSONHandler.Int64AsString
+		quote := e.int64String && v.Uint64() > maxSafeInt
+		e.appendColor(e.colors.Number)
+		if quote {
+			e.quote()
+		}
+		e.buf = strconv.AppendUint(e.buf, v.Uint64(), 10)
+		if quote {
+			e.quote()
+		}
+		e.reset(e.colors.Number)
+	case slog.KindFloat64:
+		e.float(v.Float64())
+	case slog.KindBool:
+		e.appendColor(e.colors.Boolean)
+		e.buf = strconv.AppendBool(e.buf, v.Bool())
+		e.reset(e.colors.Boolean)
+	case slog.KindDuration:
+		switch {
+		case e.durations == DurationNanos:
+			// like slog.JSONHandler
+			e.appendInt(e.colors.Number, int64(v.Duration()))
+			return
+		case e.durations == DurationMillis:
+			e.appendInt(e.colors.Number, v.Duration().Milliseconds())
+			return
+		}
+		// durations never need quoting in the flat formats
+		e.appendColor(e.colors.Duration)
+		e.quote()
+		e.buf = appendDuration(e.buf, roundDuration(v.Duration()))
+		e.quote()
+		e.reset(e.colors.Duration)
+	case slog.KindTime:
+		if e.attrTime == "" {
+			// nor do RFC 3339 times
+			e.appendColor(e.colors.time())
+			e.quote()
+			e.buf = v.Time().AppendFormat(e.buf, time.RFC3339Nano)
+			e.quote()
+			e.reset(e.colors.time())
+			return
+		}
+		e.timeValue(v.Time(), e.attrTime)
+	default:
+		e.any(v.Any())
+	}
+}
+
+// timeValue writes t formatted with layout, see appendTime.
+func (e *encoder) timeValue(t time.Time, layout string) {
+	var scratch [64]byte
+	if b, num := appendTime(scratch[:0], t, layout, e.since); num {
+		e.colored(e.colors.Number, b)
+	} else {
+		e.appendString(e.colors.time(), string(b))
+	}
+}
+
+// float appends f. NaN and ±Inf, which JSON has no numbers for, are null
+// in JSON unless nonFiniteStr is set, when they are the strings "NaN",
+// "+Inf" and "-Inf" as always in the flat formats.
+func (e *encoder) float(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if !e.flat() && !e.nonFiniteStr {
+			e.colored(e.colors.Null, []byte("null"))
+			return
+		}
+		e.appendString(e.colors.Number, nonFiniteName(f))
+		return
+	}
+	e.appendColor(e.colors.Number)
+	e.buf = appendJSONFloat(e.buf, f, e.floatPrec)
+	e.reset(e.colors.Number)
+}
+
+// maxSafeInt is the largest integer a float64, and so JavaScript, holds
+// exactly: 2^53-1.
+const maxSafeInt = 1<<53 - 1
+
+// appendInt appends n in color c.
+func (e *encoder) appendInt(c TerminalColor, n int64) {
+	e.appendColor(c)
+	e.buf = strconv.AppendInt(e.buf, n, 10)
+	e.reset(c)
+}
+
+// quote appends a double quote in JSON, where strings are always quoted.
+func (e *encoder) quote() {
+	if !e.flat() {
+		e.buf = append(e.buf, '"')
+	}
+}
+
+// any writes an arbitrary Go value.
+func (e *encoder) any(v any) {
+	switch v := v.(type) {
+	case nil:
+		e.colored(e.colors.Null, []byte("null"))
+		return
+	case *slog.Source:
+		if e.flat() {
+			e.hyperlink(func() { e.appendString(e.colors.String, v.File+":"+strconv.Itoa(v.Line)) })
+			return
+		}
+		e.openBrace('{')
+		e.key("function")
+		e.coloredString(e.colors.String, v.Function)
+		e.key("file")
+		e.hyperlink(func() { e.coloredString(e.colors.String, v.File) })
+		e.key("line")
+		e.colored(e.colors.Number, strconv.AppendInt(nil, int64(v.Line), 10))
+		e.closeBrace('}')
+		return
+	case stackTrace:
+		e.stack(v)
+		return
+	case StatusCode:
+		c := e.statusColor(v)
+		if v.Name == "" {
+			e.appendInt(c, int64(v.Code))
+			return
+		}
+		e.appendString(c, v.Name)
+		return
+	case []byte:
+		if v == nil {
+			e.colored(e.colors.Null, []byte("null"))
+			return
+		}
+		var scratch [64]byte
+		e.appendString(e.colors.String, string(appendBytes(scratch[:0], v, e.bytes, e.bytesPreview)))
+		return
+	case error:
+		if _, ok := v.(json.Marshaler); !ok {
+			e.error(v)
+			return
+		}
+	}
+	if tm, ok := v.(encoding.TextMarshaler); ok && e.flat() && !isNilPointer(v) {
+		// like slog.TextHandler
+		text, err := tm.MarshalText()
+		if err != nil {
+			e.appendString(e.colors.Error, "!ERROR:"+err.Error())
+			return
+		}
+		e.appendString(e.colors.String, string(text))
+		return
+	}
+	if s, ok := v.(fmt.Stringer); ok && !isMarshaler(v) && !isNilPointer(v) {
+		e.stringer(s)
+		return
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(e.escape&escapeHTML != 0)
+	err := enc.Encode(v)
+	if isNonFiniteError(err) {
+		// NaN and ±Inf are written as they are at the top level; a value
+		// failing for another reason, such as a cycle, keeps the first error
+		b.Reset()
+		if enc.Encode(replaceNonFinite(reflect.ValueOf(v), e.flat() || e.nonFiniteStr, 0)) == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		e.appendString(e.colors.Error, "!ERROR:"+err.Error())
+		return
+	}
+	data := bytes.TrimRight(b.Bytes(), "\n")
+	if e.maxDepth > 0 {
+		data = truncateJSON(data, e.maxDepth)
+	}
+	if e.maxValue > 0 && len(data) > e.maxValue {
+		// a cut value is no longer valid JSON, so it is written as a string
+		e.appendString(e.colors.String, capValue(string(data), e.maxValue))
+		return
+	}
+	if e.flat() {
+		// a JSON string, e.g. from a json.Marshaler, is written unquoted
+		var s string
+		if len(data) > 0 && data[0] == '"' && json.Unmarshal(data, &s) == nil {
+			e.appendString(e.colors.String, s)
+			return
+		}
+		e.appendString(e.colors.String, string(data))
+		return
+	}
+	if e.escape&escapeUnicode != 0 {
+		data = escapeNonASCII(data)
+	}
+	if e.plain {
+		// the encoded value is written as it is, without tokenizing it
+		if e.streaming() && len(data) > e.flushAt {
+			e.flush(e.buf)
+			e.buf = e.buf[:0]
+			e.flush(data)
+			return
+		}
+		e.buf = append(e.buf, data...)
+		return
+	}
+	var flush func([]byte) []byte
+	if e.streaming() && len(data) > e.flushAt {
+		flush = func(b []byte) []byte {
+			e.buf = b
+			e.flushPart()
+			return e.buf
+		}
+	}
+	e.buf = colorizeJSON(e.buf, data, e.colors, e.depth, flush)
+}
+
+// truncated replaces the objects and arrays nested too deeply in a value.
+const truncated = `"…"`
+
+// truncateJSON returns data, a valid JSON value, with the objects and
+// arrays nested more than max levels deep replaced by truncated.
+func truncateJSON(data []byte, max int) []byte {
+	var out []byte // allocated on the first truncation
+	depth, skip, inString, escaped := 0, -1, false, false
+	for i, c := range data {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > max && skip < 0 {
+				if out == nil {
+					out = append(make([]byte, 0, len(data)), data[:i]...)
+				}
+				out = append(out, truncated...)
+				skip = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+			if depth < skip {
+				skip = -1
+				continue
+			}
+		}
+		if out != nil && skip < 0 {
+			out = append(out, c)
+		}
+	}
+	if out == nil {
+		return data
+	}
+	return out
+}
+
+// isMarshaler reports whether encoding/json uses a method of v to encode it.
+func isMarshaler(v any) bool {
+	switch v.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return true
+	}
+	return false
+}
+
+// stringer writes the String result of s, with its concrete type when
+// stringerTypes is set: {"value":"…","type":"…"} in JSON or "… (type)" in
+// the flat formats.
+func (e *encoder) stringer(s fmt.Stringer) {
+	if !e.stringerTypes {
+		e.appendString(e.colors.String, s.String())
+		return
+	}
+	typ := reflect.TypeOf(s).String()
+	if e.flat() {
+		e.appendString(e.colors.String, s.String()+" ("+typ+")")
+		return
+	}
+	e.openBrace('{')
+	e.key("value")
+	e.coloredString(e.colors.String, s.String())
+	e.key("type")
+	e.coloredString(e.colors.String, typ)
+	e.closeBrace('}')
+}
+
+// isNilPointer reports whether v is a nil pointer, whose methods may panic.
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// stack writes a stack trace as an array of frames.
+func (e *encoder) stack(frames stackTrace) {
+	if e.flat() {
+		e.appendString(e.colors.Stack, strings.Join(frames, "; "))
+		return
+	}
+	e.openBrace('[')
+	for i, f := range frames {
+		if i > 0 {
+			e.punct(',')
+		}
+		e.coloredString(e.colors.Stack, f)
+	}
+	e.closeBrace(']')
+}
+
+// appendJSONFloat appends f formatted the same way as encoding/json: the
+// shortest representation that parses back to f, so 3.14 never becomes
+// 3.1400000000000001. A positive prec fixes the digits after the decimal
+// point instead.
+func appendJSONFloat(b []byte, f float64, prec int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	if prec <= 0 {
+		prec = -1
+	}
+	b = strconv.AppendFloat(b, f, format, prec, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// appendLogfmtString appends s as a logfmt value, quoting it when it is
+// empty or contains spaces, quotes, '=' or non-printable characters.
+func appendLogfmtString(b []byte, s string, esc jsonEscape) []byte {
+	if s == "" {
+		return append(b, `""`...)
+	}
+	if esc&escapeUnicode != 0 && !isASCII(s) {
+		return strconv.AppendQuoteToASCII(b, s)
+	}
+	if needsQuoting(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+// needsQuoting reports whether s must be quoted in logfmt, which includes
+// any non-ASCII rune when it is escaped.
+func (e *encoder) needsQuoting(s string) bool {
+	return needsQuoting(s) || e.escape&escapeUnicode != 0 && !isASCII(s)
+}
+
+// isASCII reports whether s holds only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// needsQuoting reports whether s must be quoted in logfmt.
+func needsQuoting(s string) bool {
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEscape selects the characters escaped in strings beyond those the
+// format requires.
+type jsonEscape uint8
+
+const (
+	escapeUnicode jsonEscape = 1 << iota // non-ASCII runes as \uXXXX
+	escapeHTML                           // <, > and & as \u003c, \u003e and \u0026 in JSON
+)
+
+// appendJSONString appends s as a quoted JSON string. Like slog.JSONHandler,
+// HTML characters are not escaped unless esc says so, and invalid UTF-8 is
+// replaced with U+FFFD. esc adds the characters to escape.
+func appendJSONString(b []byte, s string, esc jsonEscape) []byte {
+	b = append(b, '"')
+	b = appendJSONStringContent(b, s, esc)
+	return append(b, '"')
+}
+
+// appendJSONStringContent appends s escaped as in appendJSONString,
+// without the quotes.
+func appendJSONStringContent(b []byte, s string, esc jsonEscape) []byte {
+	const hex = "0123456789abcdef"
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (esc&escapeHTML == 0 || c != '<' && c != '>' && c != '&') {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if esc&escapeUnicode != 0 {
+			b = appendUnicodeEscape(append(b, s[start:i]...), r)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON but break JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(b, s[start:]...)
+}
+
+// appendUnicodeEscape appends r as a \uXXXX escape, or as a UTF-16
+// surrogate pair of them outside the Basic Multilingual Plane.
+func appendUnicodeEscape(b []byte, r rune) []byte {
+	if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		b = appendUnicodeEscape(b, r1)
+		r = r2
+	}
+	const hex = "0123456789abcdef"
+	return append(b, '\\', 'u', hex[r>>12&0xF], hex[r>>8&0xF], hex[r>>4&0xF], hex[r&0xF])
+}
+
+// escapeNonASCII returns the JSON data with every non-ASCII rune, which
+// can only occur in strings, replaced by its \uXXXX escape.
+func escapeNonASCII(data []byte) []byte {
+	var out []byte // allocated on the first non-ASCII rune
+	start := 0
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		out = appendUnicodeEscape(append(out, data[start:i]...), r)
+		i += size
+		start = i
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[start:]...)
+}
+
+// roundDuration rounds d to three significant digits, for short human
+// strings such as "1.52s" or "230ms".
+func roundDuration(d time.Duration) time.Duration {
+	p := time.Duration(1)
+	for n := d / 1000; n >= 1 || n <= -1; n /= 10 {
+		p *= 10
+	}
+	return d.Round(p)
+}
+
+// appendDuration appends d formatted like time.Duration.String, without
+// allocating.
+func appendDuration(b []byte, d time.Duration) []byte {
+	if d == 0 {
+		return append(b, "0s"...)
+	}
+	var buf [32]byte
+	w := len(buf)
+	u := uint64(d)
+	neg := d < 0
+	if neg {
+		u = -u
+	}
+	if u < uint64(time.Second) {
+		// less than a second uses a smaller unit, e.g. "1.2ms"
+		var prec int
+		w--
+		buf[w] = 's'
+		w--
+		switch {
+		case u < uint64(time.Microsecond):
+			prec = 0
+			buf[w] = 'n'
+		case u < uint64(time.Millisecond):
+			prec = 3
+			w-- // µ is two bytes
+			copy(buf[w:], "µ")
+		default:
+			prec = 6
+			buf[w] = 'm'
+		}
+		w, u = fmtFrac(buf[:w], u, prec)
+		w = fmtInt(buf[:w], u)
+	} else {
+		w--
+		buf[w] = 's'
+		w, u = fmtFrac(buf[:w], u, 9)
+		w = fmtInt(buf[:w], u%60)
+		u /= 60
+		if u > 0 {
+			w--
+			buf[w] = 'm'
+			w = fmtInt(buf[:w], u%60)
+			u /= 60
+			if u > 0 {
+				w--
+				buf[w] = 'h'
+				w = fmtInt(buf[:w], u)
+			}
+		}
+	}
+	if neg {
+		w--
+		buf[w] = '-'
+	}
+	return append(b, buf[w:]...)
+}
+
+// fmtFrac formats the fraction of v/10**prec (e.g., ".12345") into the tail
+// of buf, omitting trailing zeros and the decimal point when the fraction is
+// 0. It returns the index where the output begins and v/10**prec.
+func fmtFrac(buf []byte, v uint64, prec int) (int, uint64) {
+	w := len(buf)
+	digits := false
+	for range prec {
+		digit := v % 10
+		digits = digits || digit != 0
+		if digits {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v /= 10
+	}
+	if digits {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+// fmtInt formats v into the tail of buf and returns the index where the
+// output begins.
+func fmtInt(buf []byte, v uint64) int {
+	w := len(buf)
+	if v == 0 {
+		w--
+		buf[w] = '0'
+		return w
+	}
+	for v > 0 {
+		w--
+		buf[w] = byte(v%10) + '0'
+		v /= 10
+	}
+	return w
+}
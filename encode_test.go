@@ -0,0 +1,117 @@
+package colorjson
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+)
+
+func TestFlattenGroups(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.With("svc", "api").WithGroup("req").Info("m", "id", 1, slog.Group("user", "name", "u", slog.Group("geo", "cc", "NZ")))
+	}
+	for _, tt := range []struct {
+		format  Format
+		flatten bool
+		sep     string
+		want    string
+	}{
+		{FormatJSON, false, "", `{"level":"INFO","msg":"m","svc":"api","req":{"id":1,"user":{"name":"u","geo":{"cc":"NZ"}}}}`},
+		{FormatJSON, true, "", `{"level":"INFO","msg":"m","svc":"api","req.id":1,"req.user.name":"u","req.user.geo.cc":"NZ"}`},
+		{FormatJSON, true, "_", `{"level":"INFO","msg":"m","svc":"api","req_id":1,"req_user_name":"u","req_user_geo_cc":"NZ"}`},
+		{FormatLogfmt, false, "", `level=INFO msg=m svc=api req.id=1 req.user.name=u req.user.geo.cc=NZ`},
+		{FormatLogfmt, false, "/", `level=INFO msg=m svc=api req/id=1 req/user/name=u req/user/geo/cc=NZ`},
+	} {
+		got := output(func(h *ColorJSONHandler) {
+			h.Format, h.FlattenGroups, h.GroupSeparator = tt.format, tt.flatten, tt.sep
+		}, log)
+		if got != tt.want+"\n" {
+			t.Errorf("format %d, flatten %v, separator %q:\ngot  %s\nwant %s", tt.format, tt.flatten, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	v := map[string]any{"a": map[string]any{"b": []any{1, map[string]int{"c": 2}}}, "s": "x"}
+	for _, tt := range []struct {
+		depth int
+		want  string
+	}{
+		{0, `{"a":{"b":[1,{"c":2}]},"s":"x"}`},
+		{1, `{"a":"…","s":"x"}`},
+		{2, `{"a":{"b":"…"},"s":"x"}`},
+		{3, `{"a":{"b":[1,"…"]},"s":"x"}`},
+		{4, `{"a":{"b":[1,{"c":2}]},"s":"x"}`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.MaxDepth = tt.depth }, func(l *slog.Logger) {
+			l.Info("m", "v", v, slog.Group("g", slog.Group("h", "deep", "kept")))
+		})
+		want := `{"level":"INFO","msg":"m","v":` + tt.want + `,"g":{"h":{"deep":"kept"}}}` + "\n"
+		if got != want {
+			t.Errorf("depth %d: got %s want %s", tt.depth, got, want)
+		}
+	}
+}
+
+func TestInt64AsString(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.Info("m", "small", int64(maxSafeInt), "big", int64(maxSafeInt+2), "neg", int64(-maxSafeInt-2),
+			"ubig", uint64(1<<63), "nested", []int64{maxSafeInt + 2})
+	}
+	for _, tt := range []struct {
+		format Format
+		on     bool
+		want   string
+	}{
+		{FormatJSON, false, `{"level":"INFO","msg":"m","small":9007199254740991,"big":9007199254740993,"neg":-9007199254740993,"ubig":9223372036854775808,"nested":[9007199254740993]}`},
+		{FormatJSON, true, `{"level":"INFO","msg":"m","small":9007199254740991,"big":"9007199254740993","neg":"-9007199254740993","ubig":"9223372036854775808","nested":[9007199254740993]}`},
+		{FormatLogfmt, true, `level=INFO msg=m small=9007199254740991 big=9007199254740993 neg=-9007199254740993 ubig=9223372036854775808 nested=[9007199254740993]`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.Format, h.Int64AsString = tt.format, tt.on }, log)
+		if got != tt.want+"\n" {
+			t.Errorf("format %d, on %v:\ngot  %swant %s", tt.format, tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestFloatPrecision(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.Info("m", "pi", 3.14159, "whole", 2.0, "tiny", 1e-21, "big", 1e21, "f32", float32(0.1))
+	}
+	for _, tt := range []struct {
+		prec int
+		want string
+	}{
+		{0, `"pi":3.14159,"whole":2,"tiny":1e-21,"big":1e+21,"f32":0.10000000149011612`},
+		{2, `"pi":3.14,"whole":2.00,"tiny":1.00e-21,"big":1.00e+21,"f32":0.10`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.FloatPrecision = tt.prec }, log)
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if got != want {
+			t.Errorf("precision %d:\ngot  %swant %s", tt.prec, got, want)
+		}
+	}
+	matchesJSONHandler(t, log)
+}
+
+func TestNonFinite(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.Info("m", "nan", math.NaN(), "inf", math.Inf(1), "ninf", math.Inf(-1), "f32", float32(math.Inf(1)), "ok", 1.5)
+	}
+	for _, tt := range []struct {
+		format   Format
+		asString bool
+		want     string
+	}{
+		{FormatJSON, false, `{"level":"INFO","msg":"m","nan":null,"inf":null,"ninf":null,"f32":null,"ok":1.5}`},
+		{FormatJSON, true, `{"level":"INFO","msg":"m","nan":"NaN","inf":"+Inf","ninf":"-Inf","f32":"+Inf","ok":1.5}`},
+		{FormatLogfmt, false, `level=INFO msg=m nan=NaN inf=+Inf ninf=-Inf f32=+Inf ok=1.5`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.Format, h.NonFiniteAsString = tt.format, tt.asString }, log)
+		if got != tt.want+"\n" {
+			t.Errorf("format %d, strings %v:\ngot  %swant %s", tt.format, tt.asString, got, tt.want)
+		}
+	}
+	// logfmt matches slog.TextHandler
+	matchesStdlib(t, FormatLogfmt, log)
+}
@@ -0,0 +1,148 @@
+package colorjson
+
+import (
+	"reflect"
+	"strings"
+)
+
+// error writes an error value as an object holding its message and concrete type.
+func (e *encoder) error(err error) {
+	if errs := joinedErrors(err); errs != nil {
+		e.joinedErrors(errs)
+		return
+	}
+	var chain []error
+	if e.errorChain {
+		chain = errorChain(err)
+	}
+	if e.flat() {
+		if len(chain) == 0 {
+			e.appendString(e.colors.Error, err.Error())
+			return
+		}
+		// the messages of wrapped errors are usually part of err's, so
+		// only the types are added, e.g. "… (*fs.PathError ← syscall.Errno)"
+		var b strings.Builder
+		b.WriteString(err.Error())
+		b.WriteString(" (")
+		b.WriteString(reflect.TypeOf(err).String())
+		for _, c := range chain {
+			b.WriteString(" ← ")
+			b.WriteString(reflect.TypeOf(c).String())
+		}
+		b.WriteByte(')')
+		e.appendString(e.colors.Error, b.String())
+		return
+	}
+	e.openBrace('{')
+	e.key("msg")
+	e.coloredString(e.colors.Error, err.Error())
+	e.key("type")
+	e.coloredString(e.colors.Error, reflect.TypeOf(err).String())
+	if len(chain) > 0 {
+		e.key("chain")
+		e.openBrace('[')
+		for i, c := range chain {
+			if i > 0 {
+				e.punct(',')
+			}
+			e.openBrace('{')
+			e.key("msg")
+			e.coloredString(e.colors.Error, c.Error())
+			e.key("type")
+			e.coloredString(e.colors.Error, reflect.TypeOf(c).String())
+			e.closeBrace('}')
+		}
+		e.closeBrace(']')
+	}
+	e.closeBrace('}')
+}
+
+// joinedErrors writes the errors joined by errors.Join as an array of
+// error objects, or as their messages separated by "; " in the flat formats.
+func (e *encoder) joinedErrors(errs []error) {
+	if e.flat() {
+		var b strings.Builder
+		for i, err := range errs {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			b.WriteString(err.Error())
+		}
+		e.appendString(e.colors.Error, b.String())
+		return
+	}
+	e.openBrace('[')
+	for i, err := range errs {
+		if i > 0 {
+			e.punct(',')
+		}
+		e.error(err)
+	}
+	e.closeBrace(']')
+}
+
+// joinedErrors returns the errors joined in err by errors.Join, or nil if
+// err is not such a multi-error. Errors with an Unwrap() []error method
+// whose message adds to those of the joined errors, such as those of
+// fmt.Errorf with several %w verbs, are not split so no text is lost.
+func joinedErrors(err error) []error {
+	u, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var errs []error
+	msg := err.Error()
+	for _, w := range u.Unwrap() {
+		if w == nil {
+			continue
+		}
+		if len(errs) > 0 {
+			if !strings.HasPrefix(msg, "\n") {
+				return nil
+			}
+			msg = msg[1:]
+		}
+		m := w.Error()
+		if !strings.HasPrefix(msg, m) {
+			return nil
+		}
+		msg = msg[len(m):]
+		errs = append(errs, w)
+	}
+	if msg != "" || len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// maxErrorChain limits the wrapped errors listed by ErrorChain.
+const maxErrorChain = 32
+
+// errorChain returns the errors wrapped by err, outermost first and root
+// cause last. The errors joined by errors.Join are followed one after the
+// other.
+func errorChain(err error) []error {
+	var chain []error
+	var walk func(error)
+	walk = func(err error) {
+		var wrapped []error
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			if w := u.Unwrap(); w != nil {
+				wrapped = []error{w}
+			}
+		case interface{ Unwrap() []error }:
+			wrapped = u.Unwrap()
+		}
+		for _, w := range wrapped {
+			if w == nil || len(chain) == maxErrorChain {
+				continue
+			}
+			chain = append(chain, w)
+			walk(w)
+		}
+	}
+	walk(err)
+	return chain
+}
@@ -0,0 +1,65 @@
+package colorjson
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"testing"
+)
+
+func TestErrorChain(t *testing.T) {
+	root := errors.New("disk full")
+	pathErr := &fs.PathError{Op: "write", Path: "/tmp/x", Err: root}
+	wrapped := fmt.Errorf("saving: %w", pathErr)
+	for _, tt := range []struct {
+		format Format
+		chain  bool
+		err    error
+		want   string
+	}{
+		{FormatJSON, false, wrapped, `{"msg":"saving: write /tmp/x: disk full","type":"*fmt.wrapError"}`},
+		{FormatJSON, true, root, `{"msg":"disk full","type":"*errors.errorString"}`},
+		{FormatJSON, true, wrapped, `{"msg":"saving: write /tmp/x: disk full","type":"*fmt.wrapError","chain":[` +
+			`{"msg":"write /tmp/x: disk full","type":"*fs.PathError"},{"msg":"disk full","type":"*errors.errorString"}]}`},
+		{FormatLogfmt, false, wrapped, `"saving: write /tmp/x: disk full"`},
+		{FormatLogfmt, true, wrapped, `"saving: write /tmp/x: disk full (*fmt.wrapError ← *fs.PathError ← *errors.errorString)"`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.Format, h.ErrorChain = tt.format, tt.chain }, func(l *slog.Logger) {
+			l.Info("m", "err", tt.err)
+		})
+		want := `{"level":"INFO","msg":"m","err":` + tt.want + "}\n"
+		if tt.format == FormatLogfmt {
+			want = "level=INFO msg=m err=" + tt.want + "\n"
+		}
+		if got != want {
+			t.Errorf("format %d, chain %v:\ngot  %swant %s", tt.format, tt.chain, got, want)
+		}
+	}
+}
+
+func TestJoinedErrors(t *testing.T) {
+	a, b := errors.New("a failed"), errors.New("b failed")
+	for _, tt := range []struct {
+		format Format
+		err    error
+		want   string
+	}{
+		{FormatJSON, errors.Join(a, b), `[{"msg":"a failed","type":"*errors.errorString"},{"msg":"b failed","type":"*errors.errorString"}]`},
+		{FormatJSON, errors.Join(a, nil, errors.Join(b)), `[{"msg":"a failed","type":"*errors.errorString"},[{"msg":"b failed","type":"*errors.errorString"}]]`},
+		// the text around the %w verbs would be lost if split
+		{FormatJSON, fmt.Errorf("both: %w, %w", a, b), `{"msg":"both: a failed, b failed","type":"*fmt.wrapErrors"}`},
+		{FormatLogfmt, errors.Join(a, b), `"a failed; b failed"`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.Format = tt.format }, func(l *slog.Logger) {
+			l.Info("m", "err", tt.err)
+		})
+		want := `{"level":"INFO","msg":"m","err":` + tt.want + "}\n"
+		if tt.format == FormatLogfmt {
+			want = "level=INFO msg=m err=" + tt.want + "\n"
+		}
+		if got != want {
+			t.Errorf("format %d, %q:\ngot  %swant %s", tt.format, tt.err, got, want)
+		}
+	}
+}
@@ -33,4 +33,7 @@ func main() {
 		"code":        404,
 		"permissions": false,
 	})
+	if _, err := os.Open("/nonexistent/app.conf"); err != nil {
+		slog.Error("Failed to load config", "err", err)
+	}
 }
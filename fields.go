@@ -0,0 +1,70 @@
+package colorjson
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"runtime/debug"
+	"slices"
+)
+
+// WithHostInfo returns a handler adding the host name, the process ID and
+// service, when not empty, to every record as "host", "pid" and "service".
+// They are looked up once, here.
+func (h *ColorJSONHandler) WithHostInfo(service string) *ColorJSONHandler {
+	var attrs []slog.Attr
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, slog.String("host", host))
+	}
+	attrs = append(attrs, slog.Int("pid", os.Getpid()))
+	if service != "" {
+		attrs = append(attrs, slog.String("service", service))
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+}
+
+// WithBuildInfo returns a handler adding the main module's version and the
+// vcs.revision and vcs.time of the build, as recorded by the go command, to
+// every record. Information missing from the binary is left out.
+func (h *ColorJSONHandler) WithBuildInfo() *ColorJSONHandler {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return h
+	}
+	var attrs, vcs []slog.Attr
+	if v := info.Main.Version; v != "" {
+		attrs = append(attrs, slog.String("version", v))
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			vcs = append(vcs, slog.String("revision", s.Value))
+		case "vcs.time":
+			vcs = append(vcs, slog.String("time", s.Value))
+		}
+	}
+	if len(vcs) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "vcs", Value: slog.GroupValue(vcs...)})
+	}
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+}
+
+// WithEnv returns a handler adding the environment variables named by the
+// keys of vars to every record, under the attr keys they map to, e.g.
+// {"POD_NAME": "pod", "REGION": "region"}. The variables are read once, here;
+// unset ones are left out.
+func (h *ColorJSONHandler) WithEnv(vars map[string]string) *ColorJSONHandler {
+	var attrs []slog.Attr
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		if v, ok := os.LookupEnv(name); ok {
+			attrs = append(attrs, slog.String(vars[name], v))
+		}
+	}
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+}
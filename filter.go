@@ -0,0 +1,69 @@
+package colorjson
+
+import (
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Filter decides whether a record is logged. It returns false to drop the
+// record before any formatting is done.
+type Filter func(r slog.Record) bool
+
+// DropMessage returns a Filter dropping records whose message matches re.
+func DropMessage(re *regexp.Regexp) Filter {
+	return func(r slog.Record) bool {
+		return !re.MatchString(r.Message)
+	}
+}
+
+// DropAttr returns a Filter dropping records that have an attr named key
+// (at the top level of the record's attrs) whose value satisfies match.
+// A nil match drops every record with the attr.
+func DropAttr(key string, match func(slog.Value) bool) Filter {
+	return func(r slog.Record) bool {
+		keep := true
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == key && (match == nil || match(a.Value.Resolve())) {
+				keep = false
+			}
+			return keep
+		})
+		return keep
+	}
+}
+
+// DropLevels returns a Filter dropping records with any of the given levels.
+func DropLevels(levels ...slog.Level) Filter {
+	return func(r slog.Record) bool {
+		return !slices.Contains(levels, r.Level)
+	}
+}
+
+// matchKey reports whether one of patterns matches the attr key under
+// groups. A pattern containing a dot is matched against the dotted path of
+// the attr and of its enclosing groups, e.g. "http.headers" or "http.*";
+// other patterns against the key and the group names, e.g. "password".
+// Patterns use the syntax of path.Match.
+func matchKey(patterns []string, groups []string, key string) bool {
+	names := append(slices.Clip(groups), key)
+	for _, p := range patterns {
+		dotted := strings.Contains(p, ".")
+		prefix := ""
+		for i, name := range names {
+			if dotted {
+				if i > 0 {
+					prefix += "."
+				}
+				prefix += name
+				name = prefix
+			}
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
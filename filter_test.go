@@ -0,0 +1,32 @@
+package colorjson
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestIncludeExcludeKeys(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.With("svc", "api").Info("m", "password", "x",
+			slog.Group("http", "method", "GET", "path", "/", slog.Group("headers", "cookie", "c", "accept", "*/*")),
+			slog.Group("user", "id", 1, "password", "y"))
+	}
+	for _, tt := range []struct {
+		include, exclude []string
+		want             string
+	}{
+		{nil, nil, `"svc":"api","password":"x","http":{"method":"GET","path":"/","headers":{"cookie":"c","accept":"*/*"}},"user":{"id":1,"password":"y"}`},
+		{nil, []string{"password"}, `"svc":"api","http":{"method":"GET","path":"/","headers":{"cookie":"c","accept":"*/*"}},"user":{"id":1}`},
+		{nil, []string{"http.headers"}, `"svc":"api","password":"x","http":{"method":"GET","path":"/"},"user":{"id":1,"password":"y"}`},
+		{nil, []string{"http.*.cookie", "p*"}, `"svc":"api","http":{"method":"GET","headers":{"accept":"*/*"}},"user":{"id":1}`},
+		{[]string{"http.*"}, nil, `"http":{"method":"GET","path":"/","headers":{"cookie":"c","accept":"*/*"}}`},
+		{[]string{"svc", "id"}, nil, `"svc":"api","user":{"id":1}`},
+		{[]string{"http"}, []string{"headers"}, `"http":{"method":"GET","path":"/"}`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.IncludeKeys, h.ExcludeKeys = tt.include, tt.exclude }, log)
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if got != want {
+			t.Errorf("include %q, exclude %q:\ngot  %swant %s", tt.include, tt.exclude, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package colorjson
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Format selects the output syntax of a handler. All formats share the same
+// colors, level styling and attribute handling.
+type Format int
+
+const (
+	FormatJSON     Format = iota // colorized JSON objects (default)
+	FormatLogfmt                 // colorized logfmt key=value pairs, groups flattened with "."
+	FormatConsole                // human-readable "15:04:05 INF message key=value" lines with dimmed keys
+	FormatIndented               // colorized JSON objects spread over indented lines, one member per line
+)
+
+// BytesFormat selects how []byte attr values are written.
+type BytesFormat int
+
+const (
+	BytesBase64  BytesFormat = iota // standard base64, as encoding/json does (default)
+	BytesHex                        // lower case hex
+	BytesPreview                    // hex of the first bytes and the length, e.g. "89504e47… (2048 bytes)"
+)
+
+// DurationFormat selects how time.Duration attr values are written.
+type DurationFormat int
+
+const (
+	DurationString DurationFormat = iota // short human string, e.g. "1.52s" (default)
+	DurationNanos                        // integer nanoseconds, as slog.JSONHandler does
+	DurationMillis                       // integer milliseconds, truncated
+)
+
+// defaultBytesPreview is the number of bytes shown by BytesPreview when
+// ColorJSONHandler.BytesPreviewLen is zero.
+const defaultBytesPreview = 16
+
+// appendBytes appends p in format f. preview is the number of bytes shown
+// by BytesPreview.
+func appendBytes(b, p []byte, f BytesFormat, preview int) []byte {
+	switch f {
+	case BytesHex:
+		return hex.AppendEncode(b, p)
+	case BytesPreview:
+		if preview <= 0 {
+			preview = defaultBytesPreview
+		}
+		if len(p) <= preview {
+			return hex.AppendEncode(b, p)
+		}
+		b = hex.AppendEncode(b, p[:preview])
+		b = append(b, "… ("...)
+		b = strconv.AppendInt(b, int64(len(p)), 10)
+		return append(b, " bytes)"...)
+	}
+	return base64.StdEncoding.AppendEncode(b, p)
+}
+
+// Sentinel values for ColorJSONHandler.TimeFormat that write the record time
+// as a number of seconds, milliseconds or nanoseconds since the Unix epoch.
+const (
+	TimeFormatUnix     = "unix"
+	TimeFormatUnixMs   = "unixms"
+	TimeFormatUnixNano = "unixnano"
+)
+
+// Sentinel values for ColorJSONHandler.TimeFormat that write the record time
+// as the time elapsed since the process started or since the previous record
+// written by the handler, e.g. "+12.5ms".
+const (
+	TimeFormatSinceStart    = "+start"
+	TimeFormatSincePrevious = "+prev"
+)
+
+// processStart approximates the start of the process for TimeFormatSinceStart.
+var processStart = time.Now()
+
+// elapsed formats the time since an earlier record as "+1.234s", keeping
+// microsecond precision below a second and millisecond precision above.
+func elapsed(b []byte, d time.Duration) []byte {
+	if d < time.Second {
+		d = d.Round(time.Microsecond)
+	} else {
+		d = d.Round(time.Millisecond)
+	}
+	if d >= 0 {
+		b = append(b, '+')
+	}
+	return appendDuration(b, d)
+}
+
+// appendTime appends t formatted with layout, either a time.Format layout or
+// one of the TimeFormat sentinels. since is the reference time of the
+// elapsed formats. It reports whether the result is a number.
+func appendTime(b []byte, t time.Time, layout string, since time.Time) ([]byte, bool) {
+	switch layout {
+	case TimeFormatSinceStart, TimeFormatSincePrevious:
+		return elapsed(b, t.Sub(since)), false
+	case TimeFormatUnix:
+		return strconv.AppendInt(b, t.Unix(), 10), true
+	case TimeFormatUnixMs:
+		return strconv.AppendInt(b, t.UnixMilli(), 10), true
+	case TimeFormatUnixNano:
+		return strconv.AppendInt(b, t.UnixNano(), 10), true
+	}
+	return t.AppendFormat(b, layout), false
+}
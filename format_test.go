@@ -0,0 +1,83 @@
+package colorjson
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeFormatSincePrevious(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandler(&buf, nil)
+	h.ColorProfile, h.ForceColor = ProfileNone, false
+	h.TimeFormat = TimeFormatSincePrevious
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, d := range []time.Duration{0, 1500 * time.Microsecond, 2 * time.Second} {
+		t0 = t0.Add(d)
+		h.Handle(t.Context(), slog.NewRecord(t0, slog.LevelInfo, "m", 0))
+	}
+	want := []string{`"time":"+0s"`, `"time":"+1.5ms"`, `"time":"+2s"`}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, want[i]) {
+			t.Errorf("record %d: got %s, want %s", i, line, want[i])
+		}
+	}
+}
+
+func TestDurationFormat(t *testing.T) {
+	log := func(l *slog.Logger) {
+		l.Info("m", "a", 1520*time.Millisecond, "b", 1500*time.Microsecond, "c", -90*time.Minute, "d", time.Duration(0))
+	}
+	for _, tt := range []struct {
+		format DurationFormat
+		raw    bool
+		want   string
+	}{
+		{DurationString, false, `"a":"1.52s","b":"1.5ms","c":"-1h30m0s","d":"0s"`},
+		{DurationNanos, false, `"a":1520000000,"b":1500000,"c":-5400000000000,"d":0`},
+		{DurationMillis, false, `"a":1520,"b":1,"c":-5400000,"d":0`},
+		// the deprecated RawDurations only applies to DurationString
+		{DurationString, true, `"a":1520000000,"b":1500000,"c":-5400000000000,"d":0`},
+		{DurationMillis, true, `"a":1520,"b":1,"c":-5400000,"d":0`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.DurationFormat, h.RawDurations = tt.format, tt.raw }, log)
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if got != want {
+			t.Errorf("format %d, raw %v:\ngot  %swant %s", tt.format, tt.raw, got, want)
+		}
+	}
+	matchesStdlib(t, FormatJSON, func(l *slog.Logger) {
+		if h, ok := l.Handler().(*ColorJSONHandler); ok {
+			h.DurationFormat = DurationNanos
+		}
+		log(l)
+	})
+}
+
+func TestBytesFormat(t *testing.T) {
+	long := bytes.Repeat([]byte{0xab}, 20)
+	log := func(l *slog.Logger) { l.Info("m", "short", []byte("hi"), "long", long, "nil", []byte(nil)) }
+	for _, tt := range []struct {
+		format  BytesFormat
+		preview int
+		want    string
+	}{
+		{BytesBase64, 0, `"short":"aGk=","long":"q6urq6urq6urq6urq6urq6urq6s=","nil":null`},
+		{BytesHex, 0, `"short":"6869","long":"abababababababababababababababababababab","nil":null`},
+		{BytesPreview, 0, `"short":"6869","long":"abababababababababababababababab… (20 bytes)","nil":null`},
+		{BytesPreview, 2, `"short":"6869","long":"abab… (20 bytes)","nil":null`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.BytesFormat, h.BytesPreviewLen = tt.format, tt.preview }, log)
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if got != want {
+			t.Errorf("format %d, preview %d:\ngot  %swant %s", tt.format, tt.preview, got, want)
+		}
+	}
+	matchesJSONHandler(t, log)
+}
@@ -0,0 +1,66 @@
+package colorjson
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// formatters holds the functions registered with RegisterFormatter by the
+// type they format. hasFormatters keeps the lookup off the path of
+// applications that register none.
+var (
+	formattersMu  sync.RWMutex
+	formatters    = map[reflect.Type]func(any) slog.Value{}
+	hasFormatters atomic.Bool
+)
+
+// RegisterFormatter makes every handler write attr values of type T as the
+// value returned by format, replacing any formatter registered for T. It
+// lets an application decide once how its domain types, such as money, IDs
+// or coordinates, are rendered, including types it cannot give a LogValue
+// method. format may return a group, e.g. slog.GroupValue for a point's x
+// and y. A nil format removes the formatter of T.
+//
+// T is matched exactly: pointers to T and values nested in slices, maps
+// or structs are written as before, as are interface types.
+func RegisterFormatter[T any](format func(T) slog.Value) {
+	t := reflect.TypeFor[T]()
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if format == nil {
+		delete(formatters, t)
+	} else {
+		formatters[t] = func(v any) slog.Value { return format(v.(T)) }
+	}
+	hasFormatters.Store(len(formatters) > 0)
+}
+
+// formatValue returns v as formatted by the formatter registered for its
+// type, and false if there is none.
+func formatValue(v any) (slog.Value, bool) {
+	if !hasFormatters.Load() || v == nil {
+		return slog.Value{}, false
+	}
+	formattersMu.RLock()
+	format, ok := formatters[reflect.TypeOf(v)]
+	formattersMu.RUnlock()
+	if !ok {
+		return slog.Value{}, false
+	}
+	return format(v).Resolve(), true
+}
+
+// keyFormatter returns the function of KeyFormatters for the attr key
+// under groups: that of its dotted path, e.g. "http.latency", or else that
+// of the key itself.
+func (h *ColorJSONHandler) keyFormatter(groups []string, key string) func(slog.Value) slog.Value {
+	if len(groups) > 0 {
+		if f, ok := h.KeyFormatters[strings.Join(groups, ".")+"."+key]; ok {
+			return f
+		}
+	}
+	return h.KeyFormatters[key]
+}
@@ -0,0 +1,49 @@
+package colorjson
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestKeyFormatters(t *testing.T) {
+	ms := func(v slog.Value) slog.Value {
+		return slog.StringValue(fmt.Sprintf("%.1fms", float64(v.Duration())/float64(time.Millisecond)))
+	}
+	price := func(v slog.Value) slog.Value { return slog.StringValue(fmt.Sprintf("$%.2f", v.Float64())) }
+	for _, tt := range []struct {
+		formatters map[string]func(slog.Value) slog.Value
+		want       string
+	}{
+		{nil, `"latency":"1.25ms","price":3.5,"http":{"latency":"2ms"}`},
+		{map[string]func(slog.Value) slog.Value{"latency": ms, "price": price},
+			`"latency":"1.2ms","price":"$3.50","http":{"latency":"2.0ms"}`},
+		// a dotted path takes precedence over the key
+		{map[string]func(slog.Value) slog.Value{"latency": ms, "http.latency": func(slog.Value) slog.Value { return slog.IntValue(2) }},
+			`"latency":"1.2ms","price":3.5,"http":{"latency":2}`},
+	} {
+		got := output(func(h *ColorJSONHandler) { h.KeyFormatters = tt.formatters }, func(l *slog.Logger) {
+			l.Info("m", "latency", 1250*time.Microsecond, "price", 3.5, slog.Group("http", "latency", 2*time.Millisecond))
+		})
+		want := `{"level":"INFO","msg":"m",` + tt.want + "}\n"
+		if got != want {
+			t.Errorf("got  %swant %s", got, want)
+		}
+	}
+
+	// ReplaceAttr sees the rewritten values
+	var seen slog.Value
+	h := NewHandler(&bytes.Buffer{}, &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == "price" {
+			seen = a.Value
+		}
+		return a
+	}})
+	h.KeyFormatters = map[string]func(slog.Value) slog.Value{"price": price}
+	slog.New(h).Info("m", "price", 3.5)
+	if seen.String() != "$3.50" {
+		t.Errorf("ReplaceAttr saw %v, want the formatted value", seen)
+	}
+}
@@ -3,34 +3,47 @@ package colorjson
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// TerminalColor is an ANSI escape sequence setting the color or style of the
+// text that follows it. Sequences can be concatenated, e.g.
+// BgRedColor + WhiteColor, or built with Fg256, Bg256, FgRGB, BgRGB or Style.
 type TerminalColor string
 
 const (
-	Reset        TerminalColor = "\033[0m"
-	CyanColor    TerminalColor = "\033[36m"   // cyan
-	GreenColor   TerminalColor = "\033[32m"   // green
-	YellowColor  TerminalColor = "\033[33m"   // yellow
-	MagentaColor TerminalColor = "\033[35m"   // magenta
-	WhiteColor   TerminalColor = "\033[37m"   // white
-	BWhiteColor  TerminalColor = "\033[37;1m" // bright white
-	BBlueColor   TerminalColor = "\033[34;1m" // bright blue
-	BCyanColor   TerminalColor = "\033[36;1m" // bright cyan
-	BYellowColor TerminalColor = "\033[33;1m" // bright yellow
-	BRedColor    TerminalColor = "\033[31;1m" // bright red
-	RedColor     TerminalColor = "\033[31m"   // red
-	BlueColor    TerminalColor = "\033[34m"   // blue
-	GrayColor    TerminalColor = "\033[90m"   // gray
+	Reset         TerminalColor = "\033[0m"
+	CyanColor     TerminalColor = "\033[36m"   // cyan
+	GreenColor    TerminalColor = "\033[32m"   // green
+	YellowColor   TerminalColor = "\033[33m"   // yellow
+	MagentaColor  TerminalColor = "\033[35m"   // magenta
+	WhiteColor    TerminalColor = "\033[37m"   // white
+	BWhiteColor   TerminalColor = "\033[37;1m" // bright white
+	BBlueColor    TerminalColor = "\033[34;1m" // bright blue
+	BCyanColor    TerminalColor = "\033[36;1m" // bright cyan
+	BYellowColor  TerminalColor = "\033[33;1m" // bright yellow
+	BRedColor     TerminalColor = "\033[31;1m" // bright red
+	BGreenColor   TerminalColor = "\033[32;1m" // bright green
+	BMagentaColor TerminalColor = "\033[35;1m" // bright magenta
+	RedColor      TerminalColor = "\033[31m"   // red
+	BlueColor     TerminalColor = "\033[34m"   // blue
+	GrayColor     TerminalColor = "\033[90m"   // gray
+	DimColor      TerminalColor = "\033[2m"    // dim (faint)
 	// Additional colors
 	BoldColor      TerminalColor = "\033[1m"  // bold
 	ItalicColor    TerminalColor = "\033[3m"  // italic
 	UnderlineColor TerminalColor = "\033[4m"  // underline
 	BlackColor     TerminalColor = "\033[30m" // black
+	BgBlackColor   TerminalColor = "\033[40m" // background black
 	BgRedColor     TerminalColor = "\033[41m" // background red
 	BgGreenColor   TerminalColor = "\033[42m" // background green
 	BgYellowColor  TerminalColor = "\033[43m" // background yellow
@@ -47,320 +60,1124 @@ const (
 
 // Colors is a struct that contains the ANSI color codes for JSON syntax highlighting
 type Colors struct {
-	String     TerminalColor // string color
-	Number     TerminalColor // number color
-	Boolean    TerminalColor // boolean color
-	Null       TerminalColor // null color
-	Key        TerminalColor // key color
-	Brace      TerminalColor // brace color
-	LevelInfo  TerminalColor // level info color
-	LevelDebug TerminalColor // level debug color
-	LevelWarn  TerminalColor // level warn color
-	LevelError TerminalColor // level error color
+	String      TerminalColor // string color
+	Number      TerminalColor // number color
+	Boolean     TerminalColor // boolean color
+	Null        TerminalColor // null color
+	Key         TerminalColor // key color
+	Brace       TerminalColor // brace and bracket color
+	Punctuation TerminalColor // comma and colon color, Brace when empty
+	Error       TerminalColor // error value color
+	Stack       TerminalColor // stack trace frame color
+	Duration    TerminalColor // time.Duration value color
+	Time        TerminalColor // time.Time value color, String when empty
+	Status      TerminalColor // StatusCode value color below WARN, Number when empty
+	Changed     TerminalColor // values changed since the previous record, with DiffPrevious
+	Threshold   TerminalColor // values over their Thresholds limit, LevelWarn when empty
+	Dim         TerminalColor // keys, time and source in FormatConsole
+	LevelInfo   TerminalColor // level info color
+	LevelDebug  TerminalColor // level debug color
+	LevelWarn   TerminalColor // level warn color
+	LevelError  TerminalColor // level error color
+
+	// Rainbow, when set, colors JSON braces and keys by nesting depth,
+	// cycling through the colors, instead of with Brace and Key.
+	Rainbow []TerminalColor
+
+	// Braces, when set, colors JSON braces and brackets by nesting depth,
+	// cycling through the colors. It takes precedence over Rainbow.
+	Braces []TerminalColor
+}
+
+// punctuation returns the color of commas and colons.
+func (c Colors) punctuation() TerminalColor {
+	if c.Punctuation != "" {
+		return c.Punctuation
+	}
+	return c.Brace
+}
+
+// time returns the color of time.Time values.
+func (c Colors) time() TerminalColor {
+	if c.Time != "" {
+		return c.Time
+	}
+	return c.String
+}
+
+// status returns the color of StatusCode values.
+func (c Colors) status() TerminalColor {
+	if c.Status != "" {
+		return c.Status
+	}
+	return c.Number
+}
+
+// threshold returns the color of values over their Thresholds limit.
+func (c Colors) threshold() TerminalColor {
+	if c.Threshold != "" {
+		return c.Threshold
+	}
+	return c.LevelWarn
+}
+
+// depthColor returns the Rainbow color for depth, or base without a Rainbow.
+func (c Colors) depthColor(base TerminalColor, depth int) TerminalColor {
+	if len(c.Rainbow) == 0 || depth < 0 {
+		return base
+	}
+	return c.Rainbow[depth%len(c.Rainbow)]
+}
+
+// braceColor returns the color of the braces and brackets at depth.
+func (c Colors) braceColor(depth int) TerminalColor {
+	if len(c.Braces) == 0 || depth < 0 {
+		return c.depthColor(c.Brace, depth)
+	}
+	return c.Braces[depth%len(c.Braces)]
 }
 
 // ColorJSONHandler is a custom handler that produces colorized JSON output
 type ColorJSONHandler struct {
-	Colors      Colors // allows for customizing colors
-	out         io.Writer
-	opts        *slog.HandlerOptions
-	baseHandler slog.Handler
+	Colors Colors // allows for customizing colors
+	Format Format // output syntax, FormatJSON by default
+
+	// AccessLog writes the records of LogMiddleware, or any record with an
+	// "http" group holding a method, path and status, as Apache access log
+	// lines in place of Format. Other records are unaffected.
+	AccessLog AccessLogFormat
+
+	// StacktraceLevel enables a "stack" field holding the caller's stack
+	// trace on records at or above this level. Disabled when nil.
+	StacktraceLevel slog.Leveler
+
+	// GoroutineID adds a "goroutine_id" field holding the ID of the
+	// goroutine that logged the record, to tell apart the interleaved logs
+	// of concurrent code while debugging. The ID is parsed from
+	// runtime.Stack, which is slow and best effort; it is 0 if that fails.
+	GoroutineID bool
+
+	// ContextExtractors are called with the context passed to Handle and
+	// the attrs they return are added at the top level of every record,
+	// e.g. request or tenant IDs stored in the context.
+	ContextExtractors []func(ctx context.Context) []slog.Attr
+
+	// Sinks receive every record passed to the handler in addition to its
+	// own output, e.g. a plain JSON handler for a file or the system logger.
+	// Each sink applies its own level.
+	Sinks []slog.Handler
+
+	// Filters are applied to every record before it is formatted; a record
+	// is dropped if any filter returns false.
+	Filters []Filter
+
+	// GroupLevels overrides the minimum level for loggers within a group,
+	// keyed by the dot-separated group path set with WithGroup. The longest
+	// matching prefix wins, e.g. {"db": slog.LevelWarn, "http": slog.LevelDebug}.
+	GroupLevels map[string]slog.Leveler
+
+	// Before hooks run, in order, on every record that passes the Filters
+	// and may modify it. A hook returning an error stops the record from
+	// being written; the error is returned from Handle unless it is
+	// ErrDropRecord. After hooks run once the record has been handled.
+	Before []Hook
+	After  []Hook
+
+	// Metrics, when set, counts the records handled per level. It is shared
+	// by handlers derived with WithAttrs and WithGroup.
+	Metrics *Metrics
+
+	// SourceFormat controls how the "source" field is written. With
+	// SrcRelative, SourcePrefixes are trimmed from the path when one
+	// matches, otherwise the path is made relative to its module root.
+	// SrcFunc writes a short "pkg.Func:line" string instead.
+	SourceFormat   SourceFormat
+	SourcePrefixes []string
+
+	// SourceLink, when set, makes the "source" field a clickable OSC 8
+	// hyperlink in terminals that support it. It is a URL template where
+	// {file} is replaced by the absolute file path and {line} by the line
+	// number, e.g. "file://{file}" or "vscode://file{file}:{line}".
+	SourceLink string
+
+	// Terminator ends each record, "\n" if empty. Use "\r\n" for Windows
+	// pipelines or "\x00" for consumers reading NUL-delimited streams.
+	Terminator string
+
+	// Now, when set, replaces the time of records, except those without
+	// one, e.g. with a fixed time for stable output in examples and golden
+	// file tests. Sinks and hooks get the replaced time too.
+	Now func() time.Time
+
+	// TimeLocation, when set, converts record times to this location, e.g.
+	// time.UTC, regardless of the host's time zone.
+	TimeLocation *time.Location
+
+	// TimeFormat, when set, is the time.Format layout of the "time" field,
+	// or one of TimeFormatUnix, TimeFormatUnixMs and TimeFormatUnixNano to
+	// write it as a number. TimeFormatSinceStart and TimeFormatSincePrevious
+	// write the time elapsed since the process started or since the previous
+	// record, e.g. "+12ms"; with TimeFormatSincePrevious the first record is
+	// "+0s". The default is RFC 3339 with nanoseconds, or time.TimeOnly in
+	// FormatConsole.
+	TimeFormat string
+
+	// AttrTimeFormat, when set, is the layout of time.Time attr values, in
+	// the same form as TimeFormat. By default they use TimeFormat, unless
+	// it is one of the elapsed time formats, or else RFC 3339 with
+	// nanoseconds.
+	AttrTimeFormat string
+
+	// DurationFormat selects how time.Duration values are written in
+	// every format: as short human strings such as "1.52s" by default, or
+	// as integer nanoseconds or milliseconds for the ingestion pipelines
+	// that expect numbers.
+	DurationFormat DurationFormat
+
+	// RawDurations writes time.Duration values as integer nanoseconds.
+	// It is taken as DurationNanos while DurationFormat is left at
+	// DurationString; any other DurationFormat takes precedence.
+	//
+	// Deprecated: Set DurationFormat to DurationNanos.
+	RawDurations bool
+
+	// BytesFormat selects how []byte values are written: base64 like
+	// encoding/json, hex, or a short hex preview with the length, limited
+	// to BytesPreviewLen bytes (16 if zero).
+	BytesFormat     BytesFormat
+	BytesPreviewLen int
+
+	// StringerTypes adds the concrete type to fmt.Stringer values, which
+	// are otherwise written as the string returned by their String method,
+	// e.g. {"value":"active","type":"main.State"}. Types implementing
+	// json.Marshaler or encoding.TextMarshaler are encoded with those.
+	StringerTypes bool
+
+	// ErrorChain adds the errors wrapped by error values, found with
+	// errors.Unwrap, so the root cause shows without formatting the error
+	// with %+v: {"msg":…,"type":…,"chain":[{"msg":…,"type":…},…]} in JSON,
+	// ending with the root cause, or the types joined by " ← " after the
+	// message in the flat formats.
+	ErrorChain bool
+
+	// EscapeUnicode writes non-ASCII characters in strings as \uXXXX
+	// escapes, e.g. "caf\u00e9", for terminals and pipelines that mangle
+	// UTF-8. Strings are raw UTF-8 by default. In the flat formats, values
+	// and keys with non-ASCII characters are quoted to be escaped, while
+	// the FormatConsole message is escaped unquoted.
+	EscapeUnicode bool
+
+	// EscapeHTML writes <, > and & in JSON strings as \u003c, \u003e and
+	// \u0026, like encoding/json by default, so records can be embedded in
+	// HTML by web log viewers. It is off by default, like slog.JSONHandler.
+	EscapeHTML bool
+
+	// Int64AsString writes int64 and uint64 values beyond ±(2^53-1), which
+	// JavaScript-based log viewers silently round, as JSON strings, e.g.
+	// "id":"9007199254740993". Smaller integers stay numbers. Integers
+	// inside other Go values, such as struct fields, are left as numbers.
+	Int64AsString bool
+
+	// FloatPrecision, when positive, writes float64 values with that many
+	// digits after the decimal point, e.g. 3.140 for 3.14 with 3. Floats are
+	// otherwise written in the shortest form that parses back to the same
+	// value, like encoding/json.
+	FloatPrecision int
+
+	// NonFiniteAsString writes NaN, +Inf and -Inf float64 values, which
+	// JSON has no numbers for, as the strings "NaN", "+Inf" and "-Inf"
+	// instead of null, including those inside slog.Any values. The flat
+	// formats always write them unquoted at the top level.
+	NonFiniteAsString bool
+
+	// Indent is the string written per nesting level in FormatIndented,
+	// two spaces if empty, e.g. "\t".
+	Indent string
+
+	// CompactArrayLen and CompactArrayWidth keep the arrays of scalars
+	// with at most that many elements, and at most that many characters
+	// wide, on one line in FormatIndented, e.g. [1,2,3]. Longer arrays and
+	// arrays holding objects or arrays get a line per element. They are 8
+	// and 60 if zero; a negative value spreads every array over lines.
+	CompactArrayLen   int
+	CompactArrayWidth int
+
+	// FlattenGroups writes grouped attrs in FormatJSON as flat keys joined
+	// by GroupSeparator, e.g. "http.method":"GET" instead of
+	// "http":{"method":"GET"}, which some log aggregators prefer. The flat
+	// formats always do this. GroupSeparator is "." if empty.
+	FlattenGroups  bool
+	GroupSeparator string
+
+	// MaxDepth, when set, limits how deeply nested the objects and arrays
+	// of Go values passed with slog.Any are written; deeper ones are
+	// replaced by "…". A value that refers back to itself through pointers
+	// is reported as an error by encoding/json rather than recursing forever.
+	MaxDepth int
+
+	// StreamThreshold, when set, writes a record to the output in parts
+	// once more than this many bytes of it are encoded, instead of holding
+	// very large records in memory. Parts end between attrs and, in JSON,
+	// within large strings and slog.Any values, unless ValueRules,
+	// Thresholds or diffs need the whole value. The output stays locked
+	// until the record ends. Records are not streamed to outputs that take
+	// each write as a message, LevelWriter and RecordWriter implementations
+	// such as the syslog and Loki writers, nor to a BufferedWriter.
+	StreamThreshold int
+
+	// MaxValueSize, when set, caps the size of string values and of the
+	// encoded Go values passed with slog.Any. Larger ones are cut and end
+	// with their full length, e.g. "abc… (1048576 bytes)".
+	MaxValueSize int
+
+	// IncludeKeys, when set, keeps only the attrs matching one of its
+	// patterns, and ExcludeKeys drops those matching one of its patterns.
+	// Patterns use the syntax of path.Match. One with a dot matches the
+	// dotted path of an attr or of one of its groups, e.g. "http.*";
+	// others match a key or group name at any depth, e.g. "password".
+	// The built-in attrs are always written.
+	IncludeKeys []string
+	ExcludeKeys []string
+
+	// SortAttrs writes the attrs of a record, and those of its groups, in
+	// alphabetical order of their keys, after the built-in ones, for stable
+	// diffs between lines and golden files.
+	SortAttrs bool
+
+	// DuplicateKeys, when set, keeps only the last of the attrs with the
+	// same key in a group, or renames the earlier ones, so JSON parsers
+	// that keep one member per key don't silently drop data.
+	DuplicateKeys DuplicateKeys
+
+	// ByteSizeSuffixes opts in to writing integer attrs whose keys end in
+	// one of the suffixes, e.g. "_bytes" or "_size", as sizes like
+	// "1.2 MiB". With ByteSizeRaw the number is kept in a "<key>_raw" attr
+	// written before it.
+	ByteSizeSuffixes []string
+	ByteSizeRaw      bool
+
+	// KeyFormatters rewrites the values of the attrs with the given keys
+	// before they are written and colored, e.g. "latency" as milliseconds
+	// with one decimal or "price" with a currency symbol. A key is an attr
+	// key, matched in any group, or the dotted path of a grouped attr,
+	// e.g. "http.latency", which takes precedence. ReplaceAttr sees the
+	// rewritten values.
+	KeyFormatters map[string]func(slog.Value) slog.Value
+
+	// AlignColumns pads the time, level, source and message to fixed
+	// widths so consecutive lines align vertically. The message is padded
+	// to MessageWidth characters, 40 if zero, and the other columns to the
+	// widest seen so far.
+	AlignColumns bool
+	MessageWidth int
+
+	// TreeMode (experimental) draws the groups opened with WithGroup as a
+	// tree in FormatConsole, so the nested steps of a request read as a
+	// call tree: a "├─ name" line marks each group entered since the
+	// previous record, and records are indented under their groups. Other
+	// formats, and sinks, are written as usual. The tree follows the order
+	// records are logged in, so it reads best when requests do not interleave.
+	TreeMode bool
+
+	// DiffPrevious compares the attr values of each record with those of
+	// the previous record with the same message, painting the values that
+	// changed, or are new, in Colors.Changed and dimming the others, which
+	// makes a polling loop easy to watch. The first record of a message is
+	// written as usual.
+	DiffPrevious bool
+
+	// ValueRules color values by their value, e.g. the "status" values
+	// from 500 red and those from 400 yellow. See ValueRule.
+	ValueRules []ValueRule
+
+	// Thresholds paints the numbers above the limit of their key in
+	// Colors.Threshold, a warning background by default, e.g. {"latency":
+	// float64(500 * time.Millisecond), "queue_depth": 1000}, for fields to
+	// keep an eye on while watching a console. Keys are matched like those
+	// of KeyFormatters, and durations compared in nanoseconds. ValueRules
+	// take precedence.
+	Thresholds map[string]float64
+
+	// Highlights paint matches of their patterns within the message, e.g.
+	// request IDs or words like "timeout", in a standout color, and within
+	// keys and string values for those with All set. NewHandler adds the
+	// comma-separated patterns of COLORJSON_HIGHLIGHT, in black on bright
+	// yellow.
+	Highlights []Highlight
+
+	// TintLevel, when set, writes records at or above this level entirely
+	// in their level color instead of syntax highlighting them, making e.g.
+	// errors stand out the way journalctl does.
+	TintLevel slog.Leveler
+
+	// ColorProfile limits the colors written to those the terminal
+	// supports; richer colors are converted to the nearest supported one.
+	// NewHandler sets it to ProfileNone if the output is a file other than
+	// a terminal, such as a pipe, and else with DetectColorProfile, which
+	// honors NO_COLOR. With ProfileNone nothing is colored, so JSON output
+	// matches slog.JSONHandler byte for byte when DurationFormat is
+	// DurationNanos.
+	ColorProfile ColorProfile
+
+	// ForceColor writes colors even when ColorProfile is ProfileNone, e.g.
+	// with TERM=dumb, NO_COLOR or output to a pipe, for output read through
+	// "less -R" or captured by tmux. The colors are those TERM and COLORTERM
+	// name, the 16 basic ones for TERM=dumb. NewHandler sets it when
+	// COLORJSON_FORCE is set to anything but "", "0" or "false", unless
+	// NO_COLOR is set.
+	ForceColor bool
+
+	out    io.Writer
+	opts   slog.HandlerOptions
+	goas   []groupOrAttrs // groups and attrs from WithGroup and WithAttrs
+	groups []string       // names of the groups in goas
+	path   string         // dot-separated names of the groups in goas
+	mu     *sync.Mutex    // serializes writes to out
+	level  *slog.LevelVar // minimum level, shared with handlers derived by WithAttrs and WithGroup
+	prev   *atomic.Int64  // time of the previous record, for TimeFormatSincePrevious
+	diff   *diffState     // values of the previous records, for DiffPrevious
+	align  *alignState    // widths of the columns, for AlignColumns
+	tree   *treeState     // groups of the previous record, for TreeMode
+	emf    *EMFOptions    // adds CloudWatch metric metadata when set
+}
+
+// LevelWriter is implemented by outputs that need the level of the record
+// being written, such as the syslog writer. When the handler's writer
+// implements it, WriteLevel is called instead of Write.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level slog.Level, p []byte) (n int, err error)
+}
+
+// RecordWriter is implemented by outputs that stamp messages with the
+// time of the record, such as the syslog writer. When the handler's writer
+// implements it, WriteRecord is called instead of WriteLevel and Write.
+type RecordWriter interface {
+	io.Writer
+	WriteRecord(level slog.Level, t time.Time, p []byte) (n int, err error)
+}
+
+// groupOrAttrs holds either a group name or a list of attrs, in the order
+// they were added with WithGroup and WithAttrs.
+type groupOrAttrs struct {
+	group    string
+	attrs    []slog.Attr
+	resolved []slog.Attr // attrs after resolution and ReplaceAttr
 }
 
 // NewHandler creates a new handler for colorized JSON output
 func NewHandler(w io.Writer, opts *slog.HandlerOptions) *ColorJSONHandler {
-	// Create a buffer to store JSON output temporarily
-	buf := new(bytes.Buffer)
-
-	// Create the base JSON handler that writes to our buffer
-	baseHandler := slog.NewJSONHandler(buf, opts)
-
-	return &ColorJSONHandler{
-		out:         w,
-		opts:        opts,
-		baseHandler: baseHandler,
-		// Default colors
-		Colors: Colors{
-			String:     GreenColor,
-			Number:     YellowColor,
-			Boolean:    MagentaColor,
-			Null:       WhiteColor,
-			Key:        CyanColor,
-			Brace:      BBlueColor,
-			LevelInfo:  BWhiteColor,
-			LevelDebug: BCyanColor,
-			LevelWarn:  BYellowColor,
-			LevelError: BRedColor,
-		},
+	h := &ColorJSONHandler{
+		out:          w,
+		mu:           &sync.Mutex{},
+		prev:         &atomic.Int64{},
+		diff:         &diffState{},
+		align:        &alignState{},
+		tree:         &treeState{},
+		Colors:       envColors(),
+		ColorProfile: detectProfile(w),
+		ForceColor:   envForceColor(),
+		Highlights:   envHighlights(),
 	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	// the minimum level is kept in a LevelVar so it can be changed with SetLevel
+	if lv, ok := h.opts.Level.(*slog.LevelVar); ok {
+		h.level = lv
+	} else {
+		h.level = new(slog.LevelVar)
+		if h.opts.Level != nil {
+			h.level.Set(h.opts.Level.Level())
+		}
+		h.opts.Level = h.level
+	}
+	return h
 }
 
 // Enabled implements slog.Handler.
 func (h *ColorJSONHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.baseHandler.Enabled(ctx, level)
+	if h.enabled(level) {
+		return true
+	}
+	for _, s := range h.Sinks {
+		if s.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether records at level are written to the handler's own output.
+func (h *ColorJSONHandler) enabled(level slog.Level) bool {
+	minLevel := h.level.Level()
+	if l, ok := h.groupLevel(); ok {
+		minLevel = l
+	}
+	return level >= minLevel
+}
+
+// groupLevel returns the level from GroupLevels for the handler's group path.
+func (h *ColorJSONHandler) groupLevel() (slog.Level, bool) {
+	if len(h.GroupLevels) == 0 || h.path == "" {
+		return 0, false
+	}
+	best := -1
+	var level slog.Level
+	for prefix, l := range h.GroupLevels {
+		if len(prefix) <= best || l == nil {
+			continue
+		}
+		if h.path == prefix || strings.HasPrefix(h.path, prefix+".") {
+			best, level = len(prefix), l.Level()
+		}
+	}
+	return level, best >= 0
 }
 
 // Handle implements slog.Handler.
 func (h *ColorJSONHandler) Handle(ctx context.Context, r slog.Record) error {
-	// Create a buffer to store the JSON output
-	buf := new(bytes.Buffer)
-
-	// Use the baseHandler to format as JSON, writing to our buffer
-	tempHandler := slog.NewJSONHandler(buf, h.opts)
-	if err := tempHandler.Handle(ctx, r); err != nil {
+	if h.Now != nil && !r.Time.IsZero() {
+		r.Time = h.Now()
+	}
+	for _, keep := range h.Filters {
+		if !keep(r) {
+			return nil
+		}
+	}
+	if len(h.Before) > 0 {
+		// hooks get a copy so r itself stays on the stack
+		rc := r
+		if err := h.before(ctx, &rc); err != nil {
+			if errors.Is(err, ErrDropRecord) {
+				return nil
+			}
+			return err
+		}
+		r = rc
+	}
+	if h.Metrics != nil {
+		h.Metrics.record(r.Level, r.Time)
+	}
+	var err error
+	if h.enabled(r.Level) {
+		err = h.handle(ctx, r)
+	}
+	if len(h.Sinks) == 0 && len(h.After) == 0 {
 		return err
 	}
+	errs := []error{err}
+	for _, s := range h.Sinks {
+		if s.Enabled(ctx, r.Level) {
+			errs = append(errs, s.Handle(ctx, r))
+		}
+	}
+	if len(h.After) > 0 {
+		rc := r
+		errs = append(errs, h.after(ctx, &rc))
+	}
+	return errors.Join(errs...)
+}
+
+// profile returns the color profile output is written with.
+func (h *ColorJSONHandler) profile() ColorProfile {
+	return forcedProfile(h.ColorProfile, h.ForceColor)
+}
+
+// durationFormat returns the format of durations, DurationNanos for the
+// deprecated RawDurations unless DurationFormat is set.
+func (h *ColorJSONHandler) durationFormat() DurationFormat {
+	if h.RawDurations && h.DurationFormat == DurationString {
+		return DurationNanos
+	}
+	return h.DurationFormat
+}
+
+// terminator returns the string ending each record.
+func (h *ColorJSONHandler) terminator() string {
+	if h.Terminator == "" {
+		return "\n"
+	}
+	return h.Terminator
+}
+
+// escape returns the characters escaped in strings beyond those the format
+// requires.
+func (h *ColorJSONHandler) escape() jsonEscape {
+	var esc jsonEscape
+	if h.EscapeUnicode {
+		esc |= escapeUnicode
+	}
+	if h.EscapeHTML {
+		esc |= escapeHTML
+	}
+	return esc
+}
+
+// indent returns the string written per nesting level in FormatIndented.
+func (h *ColorJSONHandler) indent() string {
+	if h.Indent == "" {
+		return defaultIndent
+	}
+	return h.Indent
+}
+
+// compactArrayLen returns the number of elements up to which arrays stay
+// on one line in FormatIndented.
+func (h *ColorJSONHandler) compactArrayLen() int {
+	if h.CompactArrayLen == 0 {
+		return defaultCompactArrayLen
+	}
+	return h.CompactArrayLen
+}
+
+// compactArrayWidth returns the width up to which arrays stay on one line
+// in FormatIndented.
+func (h *ColorJSONHandler) compactArrayWidth() int {
+	if h.CompactArrayWidth == 0 {
+		return defaultCompactArrayWidth
+	}
+	return h.CompactArrayWidth
+}
+
+// groupSeparator returns the separator of the groups in flattened keys.
+func (h *ColorJSONHandler) groupSeparator() string {
+	if h.GroupSeparator == "" {
+		return "."
+	}
+	return h.GroupSeparator
+}
+
+// attrTimeFormat returns the layout of time.Time attr values.
+func (h *ColorJSONHandler) attrTimeFormat() string {
+	switch {
+	case h.AttrTimeFormat != "":
+		return h.AttrTimeFormat
+	case h.TimeFormat == TimeFormatSinceStart, h.TimeFormat == TimeFormatSincePrevious:
+		return ""
+	}
+	return h.TimeFormat
+}
+
+// handle writes the record to the handler's own output.
+func (h *ColorJSONHandler) handle(ctx context.Context, r slog.Record) error {
+	bp := bufPool.Get().(*[]byte)
+	defer func() {
+		// keep large buffers from pinning memory
+		if cap(*bp) <= 16<<10 {
+			bufPool.Put(bp)
+		}
+	}()
+	e := encoder{buf: (*bp)[:0], colors: h.Colors, format: h.Format, time: h.TimeFormat, attrTime: h.attrTimeFormat(), since: processStart, durations: h.durationFormat(), bytes: h.BytesFormat, bytesPreview: h.BytesPreviewLen, stringerTypes: h.StringerTypes, errorChain: h.ErrorChain, flatten: h.FlattenGroups, maxDepth: h.MaxDepth, maxValue: h.MaxValueSize, sep: h.groupSeparator(), eol: h.terminator(), highlights: h.Highlights, escape: h.escape(), int64String: h.Int64AsString, floatPrec: h.FloatPrecision, nonFiniteStr: h.NonFiniteAsString}
+	// without a color profile no escape codes are written, rather than
+	// written and stripped again
+	noColor := h.profile() == ProfileNone
+	if noColor {
+		e.colors, e.highlights, e.plain = Colors{}, nil, true
+	}
+	// indented records are written as JSON and spread over lines at the end
+	indented := h.Format == FormatIndented
+	if indented {
+		e.format = FormatJSON
+	}
+	if h.AccessLog != AccessLogOff && h.accessLine(&e, r) {
+		e.buf = downgradeANSI(e.buf, h.profile())
+		*bp = e.buf
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.write(r.Level, r.Time, e.buf)
+	}
+	if e.flatGroups() {
+		pp := prefixPool.Get().(*[]string)
+		e.prefix = (*pp)[:0]
+		defer func() {
+			*pp = e.prefix[:0]
+			prefixPool.Put(pp)
+		}()
+	}
+	var pw *partialWriter
+	if h.StreamThreshold > 0 && !indented && streamable(h.out) {
+		pw = &partialWriter{h: h, level: r.Level, time: r.Time}
+		defer pw.close()
+		e.flushAt, e.flush = h.StreamThreshold, pw.write
+	}
+	if h.TimeFormat == TimeFormatSincePrevious && h.prev != nil && !r.Time.IsZero() {
+		// the first record has no previous one to count from
+		e.since = r.Time
+		if prev := h.prev.Swap(r.Time.UnixNano()); prev != 0 {
+			e.since = time.Unix(0, prev)
+		}
+	}
+	if h.TreeMode && h.tree != nil && h.Format == FormatConsole {
+		e.treePrefix(h.groups, h.tree.enter(h.groups))
+	}
+	// a tinted line is written without syntax colors, wholly in the level color
+	var tint TerminalColor
+	if h.TintLevel != nil && r.Level >= h.TintLevel.Level() {
+		if tint, _ = e.levelColor(slog.AnyValue(r.Level)); tint != "" {
+			e.colors, e.highlights = Colors{}, nil
+			e.buf = append(e.buf, tint...)
+		}
+	}
+	e.grep = highlightsAll(e.highlights)
+	if h.AlignColumns && h.align != nil {
+		e.align, e.msgWidth = h.align, h.MessageWidth
+		if e.msgWidth == 0 {
+			e.msgWidth = defaultMessageWidth
+		}
+	}
+	e.beginRecord()
+
+	// Built-in attributes, in the same order as slog.JSONHandler
+	if !r.Time.IsZero() {
+		t := r.Time.Round(0)
+		if h.TimeLocation != nil {
+			t = t.In(h.TimeLocation)
+		}
+		e.builtin(h.replace(nil, slog.Time(slog.TimeKey, t)))
+	}
+	e.builtin(h.replace(nil, slog.Any(slog.LevelKey, r.Level)))
+	if h.opts.AddSource && r.PC != 0 {
+		if !noColor {
+			e.link = h.sourceLink(r)
+		}
+		e.builtin(h.replace(nil, h.sourceAttr(r)))
+		e.link = ""
+	}
+	e.builtin(h.replace(nil, slog.String(slog.MessageKey, r.Message)))
+
+	// only the attrs are compared, the built-in fields always differ or
+	// always match
+	if h.DiffPrevious && h.diff != nil {
+		e.diffPrev = h.diff.previous(r.Message)
+		e.diffCur = make(map[string]string)
+		defer h.diff.store(r.Message, e.diffCur)
+	}
+	if !noColor && tint == "" {
+		e.valueRules, e.thresholds = h.ValueRules, h.Thresholds
+	}
+	e.trackPath = e.diffCur != nil || len(e.valueRules) > 0 || len(e.thresholds) > 0
+
+	emf := h.emf != nil && h.Format == FormatJSON
+	if emf || h.DuplicateKeys != DuplicatesAllow || h.SortAttrs {
+		attrs := dedupe(append(h.collect(r), h.trailingAttrs(ctx, r)...), h.DuplicateKeys)
+		if h.SortAttrs {
+			attrs = sortAttrs(attrs)
+		}
+		if emf {
+			if a, ok := h.emf.metadata(r.Time, attrs); ok {
+				e.attr(a)
+			}
+		}
+		for _, a := range attrs {
+			e.attr(a)
+		}
+	} else {
+		ap := attrsPool.Get().(*[]slog.Attr)
+		*ap = h.recordAttrs((*ap)[:0], r)
+		h.writeAttrs(&e, *ap)
+		clear(*ap) // drop references to the record's values
+		attrsPool.Put(ap)
+		for _, a := range h.trailingAttrs(ctx, r) {
+			e.attr(a)
+		}
+	}
+	e.endRecord()
+	if tint != "" {
+		e.buf = append(append(e.buf[:len(e.buf)-len(e.eol)], Reset...), e.eol...)
+	}
+	if indented {
+		e.buf = append(indentJSON(e.buf[:len(e.buf)-len(e.eol)], h.indent(), h.compactArrayLen(), h.compactArrayWidth()), e.eol...)
+	}
+
+	if pw != nil {
+		*bp = e.buf
+		pw.write(e.buf)
+		return pw.err
+	}
+	e.buf = downgradeANSI(e.buf, h.profile())
+	*bp = e.buf
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.write(r.Level, r.Time, e.buf)
+}
+
+// bufPool holds the buffers records are encoded into.
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
 
-	// Get the JSON string and colorize it
-	jsonStr := buf.String()
-	colorized := colorizeJSON(jsonStr, h.Colors)
+// attrsPool holds the slices the attrs of records are collected into.
+var attrsPool = sync.Pool{
+	New: func() any {
+		as := make([]slog.Attr, 0, 16)
+		return &as
+	},
+}
 
-	// Write the colorized JSON to the output
-	_, err := fmt.Fprint(h.out, colorized)
-	return err
+// prefixPool holds the group stacks used when groups are flattened into
+// keys.
+var prefixPool = sync.Pool{
+	New: func() any {
+		p := make([]string, 0, 4)
+		return &p
+	},
+}
+
+// Flush flushes the handler's output if it buffers data, as BufferedWriter does.
+func (h *ColorJSONHandler) Flush() error {
+	f, ok := h.out.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return f.Flush()
 }
 
 // WithAttrs implements slog.Handler.
 func (h *ColorJSONHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &ColorJSONHandler{
-		out:         h.out,
-		opts:        h.opts,
-		baseHandler: h.baseHandler.WithAttrs(attrs),
+	if len(attrs) == 0 {
+		return h
 	}
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
 }
 
 // WithGroup implements slog.Handler.
 func (h *ColorJSONHandler) WithGroup(name string) slog.Handler {
-	return &ColorJSONHandler{
-		out:         h.out,
-		opts:        h.opts,
-		baseHandler: h.baseHandler.WithGroup(name),
-	}
-}
-
-// colorizeJSON adds ANSI color codes to format a JSON string
-func colorizeJSON(jsonStr string, colors Colors) string {
-	type tokenType int
-	const (
-		tokenString tokenType = iota
-		tokenNumber
-		tokenBoolean
-		tokenNull
-		tokenBrace
-		tokenKey
-		tokenColon
-		tokenComma
-		tokenOther
-		tokenLevel // New token type for log levels
-	)
-
-	var result strings.Builder
-	var tokens []struct {
-		content string
-		typ     tokenType
-	}
-
-	// First pass: tokenize the JSON
-	i := 0
-	// Track whether we're about to see a level value
-	possibleLevelKey := false
-
-	for i < len(jsonStr) {
-		c := jsonStr[i]
+	if name == "" {
+		return h
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{group: name})
+}
+
+func (h *ColorJSONHandler) withGroupOrAttrs(goa groupOrAttrs) *ColorJSONHandler {
+	h2 := *h
+	// like slog's handlers, attrs are resolved and replaced once, here,
+	// rather than for every record
+	goa.resolved = h.appendAttrs(nil, h.groups, goa.attrs)
+	h2.goas = append(slices.Clip(h.goas), goa)
+	if goa.group != "" {
+		h2.groups = append(slices.Clip(h.groups), goa.group)
+		if h2.path != "" {
+			h2.path += "."
+		}
+		h2.path += goa.group
+	}
+	if len(h.Sinks) > 0 {
+		h2.Sinks = make([]slog.Handler, len(h.Sinks))
+		for i, s := range h.Sinks {
+			if goa.group != "" {
+				h2.Sinks[i] = s.WithGroup(goa.group)
+			} else {
+				h2.Sinks[i] = s.WithAttrs(goa.attrs)
+			}
+		}
+	}
+	return &h2
+}
+
+// trailingAttrs returns the attrs written at the top level after those of
+// the record: the ContextExtractors', the goroutine ID and the stack trace.
+func (h *ColorJSONHandler) trailingAttrs(ctx context.Context, r slog.Record) []slog.Attr {
+	var attrs []slog.Attr
+	if ctx != nil {
+		for _, extract := range h.ContextExtractors {
+			attrs = h.appendAttrs(attrs, nil, extract(ctx))
+		}
+	}
+	if h.GoroutineID {
+		attrs = append(attrs, slog.Uint64("goroutine_id", goroutineID()))
+	}
+	if h.StacktraceLevel != nil && r.Level >= h.StacktraceLevel.Level() {
+		attrs = append(attrs, slog.Any("stack", callerStack(2, r.PC)))
+	}
+	return attrs
+}
+
+// collect gathers the attrs added with WithAttrs and those of the record
+// into a single list, nesting them under the groups added with WithGroup.
+// ReplaceAttr is applied and empty attrs and groups are dropped.
+func (h *ColorJSONHandler) collect(r slog.Record) []slog.Attr {
+	attrs := h.recordAttrs(make([]slog.Attr, 0, r.NumAttrs()), r)
+	for i := len(h.goas) - 1; i >= 0; i-- {
+		goa := h.goas[i]
+		if goa.group != "" {
+			if len(attrs) > 0 {
+				attrs = []slog.Attr{{Key: goa.group, Value: slog.GroupValue(attrs...)}}
+			}
+			continue
+		}
+		attrs = append(slices.Clip(goa.resolved), attrs...)
+	}
+	return attrs
+}
+
+// recordAttrs appends the record's own attrs to attrs, resolved and with
+// ReplaceAttr applied.
+func (h *ColorJSONHandler) recordAttrs(attrs []slog.Attr, r slog.Record) []slog.Attr {
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = h.appendAttr(attrs, h.groups, a)
+		return true
+	})
+	return attrs
+}
+
+// writeAttrs writes the attrs added with WithAttrs and the record's attrs
+// rec, nesting them under the groups added with WithGroup. Groups with
+// nothing in them are left out.
+func (h *ColorJSONHandler) writeAttrs(e *encoder, rec []slog.Attr) {
+	// groups followed by no attrs are left out
+	end := len(h.goas)
+	if len(rec) == 0 {
+		for end > 0 && len(h.goas[end-1].resolved) == 0 {
+			end--
+		}
+	}
+	open := 0
+	for _, goa := range h.goas[:end] {
+		if goa.group != "" {
+			e.openGroup(goa.group)
+			open++
+			continue
+		}
+		for _, a := range goa.resolved {
+			e.attr(a)
+		}
+	}
+	for _, a := range rec {
+		e.attr(a)
+	}
+	for range open {
+		e.closeGroup()
+	}
+}
+
+// appendAttrs calls appendAttr for each of as.
+func (h *ColorJSONHandler) appendAttrs(attrs []slog.Attr, groups []string, as []slog.Attr) []slog.Attr {
+	for _, a := range as {
+		attrs = h.appendAttr(attrs, groups, a)
+	}
+	return attrs
+}
+
+// appendAttr resolves a, applies ReplaceAttr and appends the result to attrs.
+// Groups are processed recursively; groups with an empty key are inlined.
+func (h *ColorJSONHandler) appendAttr(attrs []slog.Attr, groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Key != "" && len(h.ExcludeKeys) > 0 && matchKey(h.ExcludeKeys, groups, a.Key) {
+		return attrs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" && h.opts.ReplaceAttr == nil && len(h.ByteSizeSuffixes) == 0 && len(h.KeyFormatters) == 0 && len(h.IncludeKeys) == 0 && len(h.ExcludeKeys) == 0 && plainGroup(a.Value.Group()) {
+			return append(attrs, a)
+		}
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		children := h.appendAttrs(nil, groups, a.Value.Group())
+		if len(children) == 0 {
+			return attrs
+		}
+		if a.Key == "" {
+			return append(attrs, children...)
+		}
+		return append(attrs, slog.Attr{Key: a.Key, Value: slog.GroupValue(children...)})
+	}
+	if len(h.IncludeKeys) > 0 && !matchKey(h.IncludeKeys, groups, a.Key) {
+		return attrs
+	}
+	if len(h.KeyFormatters) > 0 {
+		if f := h.keyFormatter(groups, a.Key); f != nil {
+			a.Value = f(a.Value).Resolve()
+		}
+	}
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			return h.appendAttr(attrs, groups, a)
+		}
+	}
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if len(h.ByteSizeSuffixes) > 0 {
+		attrs, a = h.byteSize(attrs, a)
+	}
+	return append(attrs, a)
+}
+
+// plainGroup reports whether a group can be written as is: it is not empty
+// and has no values to resolve, empty attrs or empty groups.
+func plainGroup(as []slog.Attr) bool {
+	if len(as) == 0 {
+		return false
+	}
+	for _, a := range as {
+		switch a.Value.Kind() {
+		case slog.KindLogValuer:
+			return false
+		case slog.KindGroup:
+			if a.Key == "" || !plainGroup(a.Value.Group()) {
+				return false
+			}
+		default:
+			if a.Equal(slog.Attr{}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// replace applies ReplaceAttr to a built-in attribute.
+func (h *ColorJSONHandler) replace(groups []string, a slog.Attr) slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return a
+	}
+	a = h.opts.ReplaceAttr(groups, a)
+	a.Value = a.Value.Resolve()
+	return a
+}
+
+// sourceAttr returns the "source" attr of the record in h.SourceFormat.
+func (h *ColorJSONHandler) sourceAttr(r slog.Record) slog.Attr {
+	src := h.recordSource(r)
+	if h.SourceFormat == SrcFunc {
+		return slog.String(slog.SourceKey, shortFunc(src.Function)+":"+strconv.Itoa(src.Line))
+	}
+	return slog.Any(slog.SourceKey, src)
+}
+
+// recordSource returns the source location of the record's call site.
+func (h *ColorJSONHandler) recordSource(r slog.Record) *slog.Source {
+	fs := runtime.CallersFrames([]uintptr{r.PC})
+	f, _ := fs.Next()
+	return &slog.Source{
+		Function: f.Function,
+		File:     h.frameFile(f),
+		Line:     f.Line,
+	}
+}
+
+// colorizeJSON appends data, a JSON value nested depth levels deep in the
+// record, to dst with ANSI color codes added. It walks data once, painting
+// each token as it is read. If flush is set, dst is replaced by flush(dst)
+// after each token, which lets the caller write out what is done.
+func colorizeJSON(dst, data []byte, colors Colors, depth int, flush func([]byte) []byte) []byte {
+	paint := func(c TerminalColor, content []byte) {
+		dst = append(dst, c...)
+		dst = append(dst, content...)
+		if c != "" {
+			dst = append(dst, Reset...)
+		}
+	}
+	// whether the last key was "level", whose value gets its level color
+	levelKey := false
+	for i := 0; i < len(data); {
+		c := data[i]
 		switch c {
 		case ' ', '\t', '\n', '\r':
-			// Whitespace
 			start := i
-			for i < len(jsonStr) && (jsonStr[i] == ' ' || jsonStr[i] == '\t' || jsonStr[i] == '\n' || jsonStr[i] == '\r') {
+			for i < len(data) && isJSONSpace(data[i]) {
 				i++
 			}
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: jsonStr[start:i], typ: tokenOther})
-		case '{', '}', '[', ']':
-			// Braces/brackets
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: string(c), typ: tokenBrace})
+			dst = append(dst, data[start:i]...)
+		case '{', '[':
+			paint(colors.braceColor(depth), data[i:i+1])
+			depth++
 			i++
-		case ':':
-			// Colon
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: ":", typ: tokenColon})
+		case '}', ']':
+			depth--
+			paint(colors.braceColor(depth), data[i:i+1])
 			i++
-		case ',':
-			// Comma
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: ",", typ: tokenComma})
+		case ':', ',':
+			paint(colors.punctuation(), data[i:i+1])
 			i++
 		case '"':
-			// String or key
 			start := i
-			i++ // Skip opening quote
-			strContent := ""
-			for i < len(jsonStr) {
-				if jsonStr[i] == '\\' && i+1 < len(jsonStr) {
-					strContent += string(jsonStr[i]) + string(jsonStr[i+1])
-					i += 2 // Skip escape sequence
-					continue
-				}
-				if jsonStr[i] == '"' {
-					strContent += string(jsonStr[i])
-					i++ // Include closing quote
-					break
-				}
-				strContent += string(jsonStr[i])
-				i++
-			}
-			content := jsonStr[start:i]
-			strValue := strings.Trim(strContent, "\"")
-
-			// Look ahead to see if this is a key (followed by colon)
-			isKey := false
-			for j := i; j < len(jsonStr); j++ {
-				if jsonStr[j] == ' ' || jsonStr[j] == '\t' || jsonStr[j] == '\n' || jsonStr[j] == '\r' {
-					continue
-				}
-				if jsonStr[j] == ':' {
-					isKey = true
-				}
-				break
+			i = jsonStringEnd(data, i)
+			content := data[start:i]
+			j := i
+			for j < len(data) && isJSONSpace(data[j]) {
+				j++
 			}
-
-			if isKey {
-				// Set flag if this is the level key
-				if strValue == "level" {
-					possibleLevelKey = true
-				} else {
-					possibleLevelKey = false
+			switch {
+			case j < len(data) && data[j] == ':':
+				levelKey = string(content) == `"level"`
+				paint(colors.depthColor(colors.Key, depth-1), content)
+			case levelKey:
+				levelKey = false
+				switch string(content) {
+				case `"INFO"`:
+					paint(colors.LevelInfo, content)
+				case `"DEBUG"`:
+					paint(colors.LevelDebug, content)
+				case `"WARN"`:
+					paint(colors.LevelWarn, content)
+				case `"ERROR"`:
+					paint(colors.LevelError, content)
+				default:
+					paint(colors.String, content)
 				}
-
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: content, typ: tokenKey})
-			} else if possibleLevelKey && isLogLevel(strValue) {
-				// This is a log level value, mark it as such
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: content, typ: tokenLevel})
-				possibleLevelKey = false
-			} else {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: content, typ: tokenString})
-				possibleLevelKey = false
-			}
-		case 't':
-			// true
-			if i+3 < len(jsonStr) && jsonStr[i:i+4] == "true" {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: "true", typ: tokenBoolean})
-				i += 4
-			} else {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: string(c), typ: tokenOther})
-				i++
+			default:
+				paint(colors.String, content)
 			}
-		case 'f':
-			// false
-			if i+4 < len(jsonStr) && jsonStr[i:i+5] == "false" {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: "false", typ: tokenBoolean})
-				i += 5
-			} else {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: string(c), typ: tokenOther})
-				i++
+		case 't', 'f', 'n':
+			color, lit := colors.Boolean, "true"
+			if c == 'f' {
+				lit = "false"
+			} else if c == 'n' {
+				color, lit = colors.Null, "null"
 			}
-		case 'n':
-			// null
-			if i+3 < len(jsonStr) && jsonStr[i:i+4] == "null" {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: "null", typ: tokenNull})
-				i += 4
-			} else {
-				tokens = append(tokens, struct {
-					content string
-					typ     tokenType
-				}{content: string(c), typ: tokenOther})
+			if !bytes.HasPrefix(data[i:], []byte(lit)) {
+				dst = append(dst, c)
 				i++
+				continue
 			}
+			paint(color, data[i:i+len(lit)])
+			i += len(lit)
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-':
-			// Number
 			start := i
-			for i < len(jsonStr) && ((jsonStr[i] >= '0' && jsonStr[i] <= '9') ||
-				jsonStr[i] == '.' || jsonStr[i] == 'e' || jsonStr[i] == 'E' ||
-				jsonStr[i] == '+' || jsonStr[i] == '-') {
+			for i < len(data) && ((data[i] >= '0' && data[i] <= '9') ||
+				data[i] == '.' || data[i] == 'e' || data[i] == 'E' ||
+				data[i] == '+' || data[i] == '-') {
 				i++
 			}
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: jsonStr[start:i], typ: tokenNumber})
+			paint(colors.Number, data[start:i])
 		default:
-			tokens = append(tokens, struct {
-				content string
-				typ     tokenType
-			}{content: string(c), typ: tokenOther})
+			dst = append(dst, c)
 			i++
 		}
+		if flush != nil {
+			dst = flush(dst)
+		}
 	}
+	return dst
+}
 
-	// Second pass: colorize tokens
-	for _, token := range tokens {
-		switch token.typ {
-		case tokenBrace:
-			result.WriteString(string(colors.Brace) + token.content + string(Reset))
-		case tokenKey:
-			result.WriteString(string(colors.Key) + token.content + string(Reset))
-		case tokenString:
-			result.WriteString(string(colors.String) + token.content + string(Reset))
-		case tokenNumber:
-			result.WriteString(string(colors.Number) + token.content + string(Reset))
-		case tokenBoolean:
-			result.WriteString(string(colors.Boolean) + token.content + string(Reset))
-		case tokenNull:
-			result.WriteString(string(colors.Null) + token.content + string(Reset))
-		case tokenLevel:
-			// Apply the appropriate color based on the log level
-			levelContent := strings.Trim(token.content, "\"")
-			switch levelContent {
-			case "INFO":
-				result.WriteString(string(colors.LevelInfo) + token.content + string(Reset))
-			case "DEBUG":
-				result.WriteString(string(colors.LevelDebug) + token.content + string(Reset))
-			case "WARN":
-				result.WriteString(string(colors.LevelWarn) + token.content + string(Reset))
-			case "ERROR":
-				result.WriteString(string(colors.LevelError) + token.content + string(Reset))
-			default:
-				result.WriteString(token.content)
-			}
-		default:
-			result.WriteString(token.content)
+// isJSONSpace reports whether c is JSON whitespace.
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// jsonStringEnd returns the index just past the closing quote of the JSON
+// string starting at data[start], or len(data) if it is unterminated.
+func jsonStringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++ // skip the escaped byte
+		case '"':
+			return i + 1
 		}
 	}
-
-	return result.String()
+	return len(data)
 }
 
 // isLogLevel checks if a string is a valid log level
@@ -0,0 +1,339 @@
+package colorjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func BenchmarkHandle(b *testing.B) {
+	for _, f := range []struct {
+		name   string
+		format Format
+	}{{"json", FormatJSON}, {"logfmt", FormatLogfmt}, {"console", FormatConsole}} {
+		h := NewHandler(io.Discard, nil)
+		h.Format = f.format
+		h.ForceColor = true
+		l := slog.New(h.WithAttrs([]slog.Attr{slog.String("svc", "api")}).WithGroup("req"))
+		user := slog.Group("user", slog.String("id", "u1"), slog.Float64("score", 1.5))
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				l.LogAttrs(context.Background(), slog.LevelInfo, "request handled",
+					slog.String("method", "GET"), slog.Int("status", 200),
+					slog.Duration("took", 1234*time.Microsecond), slog.Bool("ok", true),
+					user)
+			}
+		})
+	}
+	b.Run("any", func(b *testing.B) {
+		h := NewHandler(io.Discard, nil)
+		h.ForceColor = true
+		l := slog.New(h)
+		v := map[string]any{"ids": []int{1, 2, 3}, "name": "widget", "tags": map[string]bool{"new": true}}
+		b.ReportAllocs()
+		for b.Loop() {
+			l.Info("request handled", "body", v)
+		}
+	})
+}
+
+func TestHandleAllocs(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatLogfmt, FormatConsole} {
+		h := NewHandler(io.Discard, nil)
+		h.Format = f
+		h.ForceColor = true
+		l := slog.New(h.WithAttrs([]slog.Attr{slog.String("svc", "api")}).WithGroup("req"))
+		user := slog.Group("user", slog.String("id", "u1"), slog.Float64("score", 1.5))
+		allocs := testing.AllocsPerRun(100, func() {
+			l.LogAttrs(context.Background(), slog.LevelInfo, "request handled",
+				slog.String("method", "GET"), slog.Int("status", 200),
+				slog.Duration("took", 1234*time.Microsecond), slog.Bool("ok", true),
+				user)
+		})
+		if allocs > 3 {
+			t.Errorf("format %d: %v allocs per record, want at most 3", f, allocs)
+		}
+	}
+}
+
+// matchesJSONHandler checks that h, uncolored, writes what slog.JSONHandler
+// writes for the records logged by log, with the time left out.
+func matchesJSONHandler(t *testing.T, log func(*slog.Logger)) {
+	t.Helper()
+	matchesStdlib(t, FormatJSON, log)
+}
+
+// matchesStdlib checks that h, uncolored and in format f, writes what
+// slog.JSONHandler, or slog.TextHandler for FormatLogfmt, writes for the
+// records logged by log, with the time left out.
+func matchesStdlib(t *testing.T, f Format, log func(*slog.Logger)) {
+	t.Helper()
+	opts := &slog.HandlerOptions{ReplaceAttr: dropTime}
+	var got, want bytes.Buffer
+	h := NewHandler(&got, opts)
+	h.Format = f
+	h.ColorProfile, h.ForceColor = ProfileNone, false
+	log(slog.New(h))
+	if f == FormatLogfmt {
+		log(slog.New(slog.NewTextHandler(&want, opts)))
+	} else {
+		log(slog.New(slog.NewJSONHandler(&want, opts)))
+	}
+	if got.String() != want.String() {
+		t.Errorf("got\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+// output returns what a handler set up by setup, uncolored, writes for the
+// records logged by log, with the time left out.
+func output(setup func(*ColorJSONHandler), log func(*slog.Logger)) string {
+	var buf bytes.Buffer
+	h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime})
+	h.ColorProfile, h.ForceColor = ProfileNone, false
+	setup(h)
+	log(slog.New(h))
+	return buf.String()
+}
+
+// dropTime is a ReplaceAttr function leaving out the record time.
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func TestSeparators(t *testing.T) {
+	matchesJSONHandler(t, func(l *slog.Logger) {
+		l.Info("m", slog.Group("a", "x", 1), "b", 2, slog.Group("c", slog.Group("d", "y", 3), "z", 4))
+		l.With("w", 1).WithGroup("g").With("v", 2).WithGroup("h").Info("m", "u", 3, "t", 4)
+		l.WithGroup("g").Info("m", slog.Group("e"), "x", 1, slog.Group("f"))
+	})
+}
+
+type secret string
+
+func (secret) LogValue() slog.Value { return slog.StringValue("***") }
+
+type lazyUser struct{ id string }
+
+func (u lazyUser) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.id), slog.Any("password", secret("hunter2")))
+}
+
+// loop is a LogValuer that never resolves to a plain value.
+type loop struct{}
+
+func (loop) LogValue() slog.Value { return slog.AnyValue(loop{}) }
+
+func TestLogValuer(t *testing.T) {
+	matchesJSONHandler(t, func(l *slog.Logger) {
+		l.Info("m", "pw", secret("hunter2"), "user", lazyUser{"u1"})
+		l.With("pw", secret("hunter2")).WithGroup("g").Info("m", "user", lazyUser{"u2"})
+		l.Info("m", slog.Group("g", "user", lazyUser{"u3"}))
+	})
+
+	// the depth limit of Resolve stops a LogValue chain, and its error is
+	// written in place of the value
+	var buf bytes.Buffer
+	slog.New(NewHandler(&buf, nil)).Info("m", "loop", loop{})
+	if !strings.Contains(buf.String(), "LogValue called too many times") {
+		t.Errorf("got %s, want the Resolve error", buf.String())
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatLogfmt} {
+		matchesStdlib(t, f, func(l *slog.Logger) {
+			abc := slog.Group("a", slog.Group("b", slog.Group("c", slog.Group("d", "x", 1), "y", 2), "z", 3), "w", 4)
+			l.Info("m", abc, "v", 5)
+			l.WithGroup("g").With(abc).WithGroup("h").Info("m", abc)
+			l.Info("m", slog.Group("a", slog.Group("b", slog.Group("c", "s", "deep string"))))
+		})
+	}
+}
+
+func TestEmptyGroups(t *testing.T) {
+	drop := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == "drop" {
+			return slog.Attr{}
+		}
+		return a
+	}
+	for _, f := range []Format{FormatJSON, FormatLogfmt, FormatConsole} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: drop})
+		h.Format = f
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		l := slog.New(h)
+		l.Info("m", slog.Group("empty"), "x", 1)
+		l.Info("m", slog.Group("outer", slog.Group("inner")), "x", 1)
+		l.Info("m", slog.Group("emptied", "drop", 1), "x", 1)
+		l.WithGroup("unused").Info("m")
+		l.With("x", 1).WithGroup("unused").WithGroup("too").Info("m")
+		for _, key := range []string{"empty", "outer", "inner", "emptied", "unused", "too"} {
+			if strings.Contains(buf.String(), key) {
+				t.Errorf("format %d: group %q written in\n%s", f, key, buf.String())
+			}
+		}
+	}
+}
+
+func TestSlogtest(t *testing.T) {
+	for _, colored := range []bool{false, true} {
+		var buf bytes.Buffer
+		newHandler := func(*testing.T) slog.Handler {
+			buf.Reset()
+			h := NewHandler(&buf, nil)
+			h.ColorProfile, h.ForceColor = ProfileNone, false
+			if colored {
+				h.ColorProfile, h.ForceColor = ProfileANSI16, true
+			}
+			return h
+		}
+		result := func(t *testing.T) map[string]any {
+			var m map[string]any
+			if err := json.Unmarshal(stripANSI(buf.Bytes()), &m); err != nil {
+				t.Fatalf("%v: %q", err, buf.String())
+			}
+			return m
+		}
+		t.Run(fmt.Sprint("colored=", colored), func(t *testing.T) {
+			slogtest.Run(t, newHandler, result)
+		})
+	}
+}
+
+// partsWriter records the size of the largest write.
+type partsWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *partsWriter) Write(p []byte) (int, error) {
+	w.max = max(w.max, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestStreamThreshold(t *testing.T) {
+	big := strings.Repeat("héllo \"wörld\"\n", 1<<16)
+	list := make([]string, 1<<15)
+	for i := range list {
+		list[i] = "item"
+	}
+	var w partsWriter
+	h := NewHandler(&w, nil)
+	h.ForceColor = true
+	h.StreamThreshold = 4 << 10
+	slog.New(h).Info("m", "s", big, "list", list)
+
+	if w.max > 8<<10 {
+		t.Errorf("largest write is %d bytes, want about %d", w.max, h.StreamThreshold)
+	}
+	var m struct {
+		S    string
+		List []string
+	}
+	if err := json.Unmarshal(stripANSI(w.Bytes()), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.S != big || len(m.List) != len(list) {
+		t.Errorf("streamed values differ from those logged")
+	}
+}
+
+// levelWriter is a LevelWriter recording its writes.
+type levelWriter struct{ writes []string }
+
+func (w *levelWriter) Write(p []byte) (int, error) { return w.WriteLevel(slog.LevelInfo, p) }
+
+func (w *levelWriter) WriteLevel(_ slog.Level, p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestStreamThresholdMessages(t *testing.T) {
+	big := strings.Repeat("x", 64<<10)
+	var lw levelWriter
+	var buf bytes.Buffer
+	bw := NewBufferedWriter(&buf, 100<<10, 0)
+	for _, out := range []io.Writer{&lw, bw} {
+		h := NewHandler(out, nil)
+		h.StreamThreshold = 1 << 10
+		slog.New(h).Info("m", "s", big, "t", big)
+	}
+	if len(lw.writes) != 1 || !json.Valid(stripANSI([]byte(lw.writes[0]))) {
+		t.Errorf("record split into %d writes to a LevelWriter", len(lw.writes))
+	}
+	// the record is larger than the buffer, so it is written at once
+	if !json.Valid(stripANSI(buf.Bytes())) {
+		t.Errorf("BufferedWriter flushed part of a record, %d bytes", buf.Len())
+	}
+}
+
+func FuzzHandle(f *testing.F) {
+	f.Add("msg", "key", "value", int64(1), 1.5, []byte("raw"))
+	f.Add("\x00\x1b[31m ", "\xff\"k", "v\\\n", int64(-1<<63), math.NaN(), []byte{0xff, 0})
+	f.Add("", "", "", int64(0), math.Inf(-1), []byte(nil))
+	f.Fuzz(func(t *testing.T, msg, key, s string, n int64, x float64, b []byte) {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{AddSource: true})
+		h.ForceColor = true
+		l := slog.New(h.WithAttrs([]slog.Attr{slog.String(key, s)}).WithGroup(key))
+		l.Info(msg, key, s, "n", n, "x", x, "b", b,
+			slog.Group(key, key, map[string]any{key: s, "x": x}),
+			"err", errors.New(s), "list", []any{s, n, b})
+		for line := range bytes.Lines(buf.Bytes()) {
+			if plain := stripANSI(line); !json.Valid(plain) {
+				t.Fatalf("invalid JSON: %q", plain)
+			}
+		}
+	})
+}
+
+func TestNoColor(t *testing.T) {
+	type point struct{ X, Y int }
+	matchesJSONHandler(t, func(l *slog.Logger) {
+		l.Info("m", "map", map[string]any{"level": "ERROR", "ok": true, "n": nil}, "point", point{1, 2})
+		l.WithGroup("g").Warn("m", "list", []any{"a", 1.5, []int{1}}, "html", "<b>&</b>")
+	})
+}
+
+func TestColorProfile(t *testing.T) {
+	for _, tt := range []struct {
+		profile ColorProfile
+		want    string // a sequence the output must have
+		not     []string
+	}{
+		{ProfileTrueColor, "\033[38;2;255;0;0m", nil},
+		{ProfileANSI256, "\033[38;5;", []string{"38;2;"}},
+		{ProfileANSI16, "\033[", []string{"38;2;", "38;5;"}},
+		{ProfileNone, "", []string{"\033"}},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, nil)
+		h.ColorProfile, h.ForceColor = tt.profile, false
+		h.Colors.Key = FgRGB(255, 0, 0)
+		h.Colors.String = Fg256(200)
+		slog.New(h).Info("m", "k", "v")
+		out := buf.String()
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("profile %d: %q lacks %q", tt.profile, out, tt.want)
+		}
+		for _, s := range tt.not {
+			if strings.Contains(out, s) {
+				t.Errorf("profile %d: %q has %q", tt.profile, out, s)
+			}
+		}
+	}
+}
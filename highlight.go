@@ -0,0 +1,96 @@
+package colorjson
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Highlight paints the parts of a record's message matching Pattern in
+// Color, e.g. {regexp.MustCompile(`timeout|refused`), BgRedColor}.
+type Highlight struct {
+	Pattern *regexp.Regexp
+	Color   TerminalColor
+	All     bool // also paint matches in keys and string values
+}
+
+// highlightEnv holds comma-separated patterns NewHandler highlights in
+// keys, messages and values, e.g. COLORJSON_HIGHLIGHT=req-42,timeout.
+const highlightEnv = "COLORJSON_HIGHLIGHT"
+
+// envHighlightColor is the color of the matches of highlightEnv.
+var envHighlightColor = Style{Fg: Black, Bg: BrightYellow}.Color()
+
+// envHighlights returns the highlights of the patterns in highlightEnv.
+// Patterns that are not valid regular expressions match literally.
+func envHighlights() []Highlight {
+	var hs []Highlight
+	for p := range strings.SplitSeq(os.Getenv(highlightEnv), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			re = regexp.MustCompile(regexp.QuoteMeta(p))
+		}
+		hs = append(hs, Highlight{Pattern: re, Color: envHighlightColor, All: true})
+	}
+	return hs
+}
+
+// highlightsAll reports whether any of the highlights applies to keys and
+// values.
+func highlightsAll(highlights []Highlight) bool {
+	for _, hl := range highlights {
+		if hl.All {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight returns msg, written in color base, with the matches of the
+// highlights painted in their colors. When matches overlap, the earlier
+// rule wins. With all, only the rules with All set are used, as for keys
+// and values.
+func highlight(msg []byte, base TerminalColor, highlights []Highlight, all bool) []byte {
+	type span struct {
+		start, end int
+		color      TerminalColor
+	}
+	var spans []span
+	for _, hl := range highlights {
+		if hl.Pattern == nil || hl.Color == "" || all && !hl.All {
+			continue
+		}
+	next:
+		for _, m := range hl.Pattern.FindAllIndex(msg, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			for _, s := range spans {
+				if m[0] < s.end && s.start < m[1] {
+					continue next
+				}
+			}
+			spans = append(spans, span{m[0], m[1], hl.Color})
+		}
+	}
+	if len(spans) == 0 {
+		return msg
+	}
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+	var b []byte
+	last := 0
+	for _, s := range spans {
+		b = append(b, msg[last:s.start]...)
+		b = append(b, s.color...)
+		b = append(b, msg[s.start:s.end]...)
+		b = append(b, Reset...)
+		b = append(b, base...)
+		last = s.end
+	}
+	return append(b, msg[last:]...)
+}
@@ -0,0 +1,35 @@
+package colorjson
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Hook is called with each record passed to the handler. Before hooks may
+// modify the record, e.g. add attrs or change its message, and veto the
+// write by returning an error; After hooks see the record as it was written.
+type Hook func(ctx context.Context, r *slog.Record) error
+
+// ErrDropRecord can be returned by a Before hook to drop the record without
+// reporting an error from Handle.
+var ErrDropRecord = errors.New("colorjson: drop record")
+
+// before runs the Before hooks in order, stopping at the first error.
+func (h *ColorJSONHandler) before(ctx context.Context, r *slog.Record) error {
+	for _, hook := range h.Before {
+		if err := hook(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after runs all the After hooks and joins their errors.
+func (h *ColorJSONHandler) after(ctx context.Context, r *slog.Record) error {
+	var errs []error
+	for _, hook := range h.After {
+		errs = append(errs, hook(ctx, r))
+	}
+	return errors.Join(errs...)
+}
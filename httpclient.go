@@ -0,0 +1,103 @@
+package colorjson
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Transport is an http.RoundTripper that logs every outgoing request and
+// its response as an "httpc" group, complementing server-side request logging.
+type Transport struct {
+	Base    http.RoundTripper // underlying transport, http.DefaultTransport if nil
+	Logger  *slog.Logger      // logger to write to, slog.Default() if nil
+	Retries int               // number of retries after a transport error, for idempotent requests that can be replayed
+}
+
+// NewTransport creates a Transport that logs requests made through base to logger.
+func NewTransport(base http.RoundTripper, logger *slog.Logger) *Transport {
+	return &Transport{Base: base, Logger: logger}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	logger := t.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	retries := 0
+	for err != nil && retries < t.Retries && idempotent(req) && req.Context().Err() == nil {
+		r, ok := replay(req)
+		if !ok {
+			break
+		}
+		retries++
+		resp, err = base.RoundTrip(r)
+	}
+
+	attrs := []any{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+	}
+	var level slog.Level
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	} else {
+		level = statusLevel(resp.StatusCode)
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	attrs = append(attrs,
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("retries", retries),
+	)
+	logger.LogAttrs(req.Context(), level, "http client request", slog.Group("httpc", attrs...))
+	return resp, err
+}
+
+// idempotent reports whether req can be sent again without duplicating its
+// side effects: its method is idempotent, or it carries an Idempotency-Key
+// header, as net/http itself decides before retrying.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// replay returns a copy of req with a fresh body so it can be sent again.
+func replay(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, true
+}
+
+// statusLevel maps an HTTP status code to the level it is logged at.
+func statusLevel(code int) slog.Level {
+	switch {
+	case code >= 500:
+		return slog.LevelError
+	case code >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,69 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogMiddleware(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		bytes   int
+	}{
+		{"ok", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "hello") }, 200, 5},
+		{"not found", http.NotFound, 404, 19},
+		{"early hints", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+		}, 201, 0},
+		{"hijacked", func(w http.ResponseWriter, r *http.Request) {
+			conn, brw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			brw.Flush()
+		}, 101, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logged := make(chan struct{})
+			mw := LogMiddleware(slog.New(slog.NewJSONHandler(&buf, nil)))(tt.handler)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(logged)
+				mw.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL + "/a/b?x=1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			<-logged
+
+			var line struct {
+				HTTP struct {
+					Method, Path, URI string
+					Status, Bytes     int
+				}
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("%v: %s", err, buf.String())
+			}
+			h := line.HTTP
+			if h.Method != "GET" || h.Path != "/a/b" || h.URI != "/a/b?x=1" || h.Status != tt.status || h.Bytes != tt.bytes {
+				t.Errorf("logged %+v, want status %d and %d bytes", h, tt.status, tt.bytes)
+			}
+		})
+	}
+}
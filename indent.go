@@ -0,0 +1,179 @@
+package colorjson
+
+import "bytes"
+
+// Defaults of ColorJSONHandler.Indent, CompactArrayLen and CompactArrayWidth.
+const (
+	defaultIndent            = "  "
+	defaultCompactArrayLen   = 8
+	defaultCompactArrayWidth = 60
+)
+
+// indentJSON spreads the colored single-line JSON object b over lines, one
+// member or element per line indented by indent for each level of nesting.
+// Arrays of at most maxLen scalars, at most maxWidth characters wide, and
+// empty objects and arrays stay on one line.
+func indentJSON(b []byte, indent string, maxLen, maxWidth int) []byte {
+	out := make([]byte, 0, len(b)+len(b)/2)
+	var open []bool // whether each open object or array is spread over lines
+	spread := func() bool { return len(open) > 0 && open[len(open)-1] }
+	newline := func(depth int) {
+		out = append(out, '\n')
+		for range depth {
+			out = append(out, indent...)
+		}
+	}
+	// resets ending the color of the last token stay on its line
+	copyResets := func(i int) int {
+		for bytes.HasPrefix(b[i:], []byte(Reset)) {
+			out = append(out, Reset...)
+			i += len(Reset)
+		}
+		return i
+	}
+	inString := false
+	for i := 0; i < len(b); {
+		if n := escapeLen(b, i); n > 0 {
+			if !inString && spread() {
+				j := i
+				for m := escapeLen(b, j); m > 0; m = escapeLen(b, j) {
+					j += m
+				}
+				if j < len(b) && (b[j] == '}' || b[j] == ']') {
+					// a closing bracket starts a line together with its color
+					i = copyResets(i)
+					newline(len(open) - 1)
+					out = append(append(out, b[i:j]...), b[j])
+					open = open[:len(open)-1]
+					i = j + 1
+					continue
+				}
+			}
+			out = append(out, b[i:i+n]...)
+			i += n
+			continue
+		}
+		c := b[i]
+		out = append(out, c)
+		i++
+		if inString {
+			switch c {
+			case '\\':
+				if i < len(b) {
+					out = append(out, b[i])
+					i++
+				}
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			s := !closesNext(b, i) && (c == '{' || !compactArray(b, i, maxLen, maxWidth))
+			open = append(open, s)
+			if s {
+				i = copyResets(i)
+				newline(len(open))
+			}
+		case '}', ']':
+			if spread() {
+				// uncolored, so not seen before the escape sequence
+				out = out[:len(out)-1]
+				newline(len(open) - 1)
+				out = append(out, c)
+			}
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case ',':
+			if spread() {
+				i = copyResets(i)
+				newline(len(open))
+			}
+		case ':':
+			if spread() {
+				i = copyResets(i)
+				out = append(out, ' ')
+			}
+		}
+	}
+	return out
+}
+
+// closesNext reports whether the next character after b[i:]'s escape
+// sequences closes an object or array, making it empty.
+func closesNext(b []byte, i int) bool {
+	for n := escapeLen(b, i); n > 0; n = escapeLen(b, i) {
+		i += n
+	}
+	return i < len(b) && (b[i] == '}' || b[i] == ']')
+}
+
+// compactArray reports whether the array starting at b[i:], after its
+// opening bracket, holds only scalars, at most maxLen of them and at most
+// maxWidth characters wide.
+func compactArray(b []byte, i, maxLen, maxWidth int) bool {
+	if maxLen < 0 || maxWidth < 0 {
+		return false
+	}
+	n, width := 1, 0
+	inString := false
+	for i < len(b) {
+		if m := escapeLen(b, i); m > 0 {
+			i += m
+			continue
+		}
+		c := b[i]
+		i++
+		width++
+		switch {
+		case inString && c == '\\':
+			i++
+			width++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			return false
+		case c == ',':
+			n++
+		case c == ']':
+			return n <= maxLen && width-1 <= maxWidth
+		}
+		if n > maxLen || width > maxWidth+1 {
+			return false
+		}
+	}
+	return false
+}
+
+// escapeLen returns the length of the terminal escape sequence at b[i:], a
+// CSI sequence such as a color or an OSC one such as a hyperlink, or 0 if
+// there is none.
+func escapeLen(b []byte, i int) int {
+	if b[i] != '\033' || i+1 >= len(b) {
+		return 0
+	}
+	j := i + 2
+	switch b[i+1] {
+	case '[':
+		// ended by a byte in 0x40-0x7e
+		for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+			j++
+		}
+		return min(j+1, len(b)) - i
+	case ']':
+		// ended by ESC \ or BEL
+		for j < len(b) && b[j] != '\a' && (b[j] != '\033' || j+1 >= len(b) || b[j+1] != '\\') {
+			j++
+		}
+		if j < len(b) && b[j] == '\033' {
+			j++
+		}
+		return min(j+1, len(b)) - i
+	}
+	return 0
+}
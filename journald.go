@@ -0,0 +1,159 @@
+package colorjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournaldHandler writes records to the systemd journal using its native
+// protocol. The message, priority and source location are sent as the
+// standard MESSAGE, PRIORITY and CODE_* fields and attrs become custom fields
+// named after their upper-cased keys, with groups joined by "_". Attrs that
+// would be named like the standard fields, e.g. "message", are prefixed
+// with "F_" instead. Entries too large for a datagram are sent through a
+// sealed memfd.
+// Add it to ColorJSONHandler.Sinks to keep colored console output as well.
+type JournaldHandler struct {
+	h          *ColorJSONHandler // tracks groups, attrs and options
+	identifier string
+	conn       net.Conn
+	mu         *sync.Mutex
+}
+
+// NewJournaldHandler connects to the local journal. identifier is sent as
+// SYSLOG_IDENTIFIER with every entry.
+func NewJournaldHandler(identifier string, opts *slog.HandlerOptions) (*JournaldHandler, error) {
+	conn, err := dialJournal()
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldHandler{
+		h:          NewHandler(nil, opts),
+		identifier: identifier,
+		conn:       conn,
+		mu:         &sync.Mutex{},
+	}, nil
+}
+
+// Enabled implements slog.Handler.
+func (j *JournaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return j.h.enabled(level)
+}
+
+// Handle implements slog.Handler.
+func (j *JournaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var b bytes.Buffer
+	appendJournalField(&b, "MESSAGE", r.Message)
+	appendJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(r.Level)))
+	if j.identifier != "" {
+		appendJournalField(&b, "SYSLOG_IDENTIFIER", j.identifier)
+	}
+	if j.h.opts.AddSource && r.PC != 0 {
+		src := j.h.recordSource(r)
+		appendJournalField(&b, "CODE_FILE", src.File)
+		appendJournalField(&b, "CODE_LINE", strconv.Itoa(src.Line))
+		appendJournalField(&b, "CODE_FUNC", src.Function)
+	}
+	appendJournalAttrs(&b, "", j.h.collect(r))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return sendJournal(j.conn, b.Bytes())
+}
+
+// WithAttrs implements slog.Handler.
+func (j *JournaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	j2 := *j
+	j2.h = j.h.WithAttrs(attrs).(*ColorJSONHandler)
+	return &j2
+}
+
+// WithGroup implements slog.Handler.
+func (j *JournaldHandler) WithGroup(name string) slog.Handler {
+	j2 := *j
+	j2.h = j.h.WithGroup(name).(*ColorJSONHandler)
+	return &j2
+}
+
+// Close closes the connection to the journal.
+func (j *JournaldHandler) Close() error {
+	return j.conn.Close()
+}
+
+// appendJournalAttrs appends attrs as journal fields, prefixing keys with prefix.
+func appendJournalAttrs(b *bytes.Buffer, prefix string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		key := journalFieldName(prefix + a.Key)
+		if journalReserved[key] {
+			key = "F_" + key
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			appendJournalAttrs(b, key+"_", a.Value.Group())
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		var val string
+		switch a.Value.Kind() {
+		case slog.KindAny:
+			if err, ok := a.Value.Any().(error); ok {
+				val = err.Error()
+			} else if data, err := json.Marshal(a.Value.Any()); err == nil {
+				val = string(data)
+			} else {
+				val = a.Value.String()
+			}
+		default:
+			val = a.Value.String()
+		}
+		appendJournalField(b, key, val)
+	}
+}
+
+// journalReserved are the fields written by JournaldHandler itself, which
+// attrs must not repeat.
+var journalReserved = map[string]bool{
+	"MESSAGE": true, "PRIORITY": true, "SYSLOG_IDENTIFIER": true,
+	"CODE_FILE": true, "CODE_LINE": true, "CODE_FUNC": true,
+}
+
+// journalFieldName converts an attr key to a valid journal field name:
+// upper case letters, digits and underscores, not starting with an underscore
+// or a digit.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	s := strings.TrimLeft(string(name), "_")
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "F_" + s
+	}
+	return s
+}
+
+// appendJournalField appends a field in the native journal protocol format.
+// Values containing newlines use the length-prefixed binary form.
+func appendJournalField(b *bytes.Buffer, key, val string) {
+	b.WriteString(key)
+	if !strings.Contains(val, "\n") {
+		b.WriteByte('=')
+		b.WriteString(val)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(val)))
+	b.WriteString(val)
+	b.WriteByte('\n')
+}
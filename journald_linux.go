@@ -0,0 +1,98 @@
+package colorjson
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// journalSocket is the path of the journal's native protocol socket
+const journalSocket = "/run/systemd/journal/socket"
+
+func dialJournal() (net.Conn, error) {
+	return net.Dial("unixgram", journalSocket)
+}
+
+// sendJournal writes the entry b to the journal. An entry too large for a
+// datagram is written to a sealed memfd instead, whose descriptor is sent
+// on its own, as the native protocol expects.
+func sendJournal(conn net.Conn, b []byte) error {
+	_, err := conn.Write(b)
+	if err == nil || !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return err
+	}
+	f, err := sealedMemfd(b)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = uc.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), nil)
+	return err
+}
+
+// memfdCreate is the memfd_create system call number of the architecture,
+// which package syscall does not define everywhere.
+var memfdCreate = map[string]uintptr{
+	"386": 356, "amd64": 319, "arm": 385, "arm64": 279, "loong64": 279,
+	"mips": 4354, "mipsle": 4354, "mips64": 5314, "mips64le": 5314,
+	"ppc64": 360, "ppc64le": 360, "riscv64": 279, "s390x": 350,
+}[runtime.GOARCH]
+
+// memfd_create and fcntl flags missing from package syscall.
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fAddSeals        = 1033
+	fSealSealShrink  = 0x1 | 0x2 // F_SEAL_SEAL | F_SEAL_SHRINK
+	fSealGrowWrite   = 0x4 | 0x8 // F_SEAL_GROW | F_SEAL_WRITE
+	journalMemfdName = "journal-entry"
+)
+
+// sealedMemfd returns a memfd holding b, sealed against any change.
+func sealedMemfd(b []byte) (*os.File, error) {
+	if memfdCreate == 0 {
+		return nil, errors.New("colorjson: memfd_create is not supported on " + runtime.GOARCH)
+	}
+	name, err := syscall.BytePtrFromString(journalMemfdName)
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(memfdCreate, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("memfd_create", errno)
+	}
+	f := os.NewFile(fd, journalMemfdName)
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSealSealShrink|fSealGrowWrite); errno != 0 {
+		f.Close()
+		return nil, os.NewSyscallError("fcntl", errno)
+	}
+	return f, nil
+}
+
+// JournalStreamAttached reports whether stderr is connected to the journal,
+// as is the case for services started by systemd. Output written to stderr
+// then already ends up in the journal.
+func JournalStreamAttached() bool {
+	dev, ino, ok := strings.Cut(os.Getenv("JOURNAL_STREAM"), ":")
+	if !ok {
+		return false
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(os.Stderr.Fd()), &st); err != nil {
+		return false
+	}
+	return dev == strconv.FormatUint(uint64(st.Dev), 10) && ino == strconv.FormatUint(uint64(st.Ino), 10)
+}
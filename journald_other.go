@@ -0,0 +1,23 @@
+//go:build !linux
+
+package colorjson
+
+import (
+	"errors"
+	"net"
+)
+
+func dialJournal() (net.Conn, error) {
+	return nil, errors.New("colorjson: journald is only supported on linux")
+}
+
+func sendJournal(conn net.Conn, b []byte) error {
+	_, err := conn.Write(b)
+	return err
+}
+
+// JournalStreamAttached reports whether stderr is connected to the journal.
+// It always returns false on systems without journald.
+func JournalStreamAttached() bool {
+	return false
+}
@@ -0,0 +1,76 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// journalConn keeps the entries written to it instead of sending them.
+type journalConn struct {
+	net.Conn
+	entries []string
+}
+
+func (c *journalConn) Write(b []byte) (int, error) {
+	c.entries = append(c.entries, string(b))
+	return len(b), nil
+}
+
+// noJSON fails to marshal, so journal fields fall back to its String form.
+type noJSON struct{}
+
+func (noJSON) MarshalJSON() ([]byte, error) { return nil, errors.New("no json") }
+func (noJSON) String() string               { return "plain" }
+
+func TestJournaldHandler(t *testing.T) {
+	long := func(key, val string) string {
+		var b bytes.Buffer
+		b.WriteString(key + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(val)))
+		b.WriteString(val + "\n")
+		return b.String()
+	}
+	const head = "MESSAGE=m\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\n"
+	for _, tt := range []struct {
+		name string
+		log  func(l *slog.Logger)
+		want string
+	}{
+		{"attrs", func(l *slog.Logger) {
+			l.Info("m", "user-id", 7, "ok", true, "d", time.Second)
+		}, head + "USER_ID=7\nOK=true\nD=1s\n"},
+		{"groups", func(l *slog.Logger) {
+			l.WithGroup("req").Info("m", slog.Group("http", "method", "GET"))
+		}, head + "REQ_HTTP_METHOD=GET\n"},
+		{"reserved", func(l *slog.Logger) {
+			l.Info("m", "message", "x", "priority", 1)
+		}, head + "F_MESSAGE=x\nF_PRIORITY=1\n"},
+		{"names", func(l *slog.Logger) {
+			l.Info("m", "_hidden", 1, "2fa", true, "é", "x", "__", "dropped")
+		}, head + "HIDDEN=1\nF_2FA=true\n"},
+		{"any", func(l *slog.Logger) {
+			l.Info("m", "err", errors.New("boom"), "tags", []string{"a", "b"}, "v", noJSON{})
+		}, head + "ERR=boom\nTAGS=[\"a\",\"b\"]\nV=plain\n"},
+		{"newline", func(l *slog.Logger) {
+			l.Warn("two\nlines", "trace", "a\nb")
+		}, long("MESSAGE", "two\nlines") + "PRIORITY=4\nSYSLOG_IDENTIFIER=app\n" + long("TRACE", "a\nb")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &journalConn{}
+			j := &JournaldHandler{h: NewHandler(nil, nil), identifier: "app", conn: conn, mu: &sync.Mutex{}}
+			tt.log(slog.New(j))
+			if len(conn.entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(conn.entries))
+			}
+			if got := conn.entries[0]; got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
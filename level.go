@@ -0,0 +1,62 @@
+package colorjson
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// SetLevel changes the minimum level of the handler and all handlers derived
+// from it with WithAttrs and WithGroup. If the handler was created with a
+// *slog.LevelVar as HandlerOptions.Level, that variable is updated.
+func (h *ColorJSONHandler) SetLevel(level slog.Level) {
+	h.level.Set(level)
+}
+
+// Level returns the current minimum level.
+func (h *ColorJSONHandler) Level() slog.Level {
+	return h.level.Level()
+}
+
+// LevelHandler returns an http.Handler to inspect and change the handler's
+// level at runtime. GET responds with the current level as {"level":"INFO"}.
+// PUT accepts the same JSON body, or a "level" form value, and responds with
+// the new level:
+//
+//	curl -X PUT -d '{"level":"debug"}' localhost:8080/loglevel
+func (h *ColorJSONHandler) LevelHandler() http.Handler {
+	type payload struct {
+		Level *slog.Level `json:"level"`
+	}
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var level slog.Level
+			if v := r.FormValue("level"); v != "" {
+				if err := level.UnmarshalText([]byte(v)); err != nil {
+					reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+					return
+				}
+			} else {
+				p := payload{Level: &level}
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+					return
+				}
+			}
+			h.SetLevel(level)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "only GET and PUT are supported"})
+			return
+		}
+		level := h.Level()
+		reply(w, http.StatusOK, payload{Level: &level})
+	})
+}
@@ -0,0 +1,260 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LokiOptions configures a LokiWriter.
+type LokiOptions struct {
+	URL           string            // push endpoint, e.g. http://localhost:3100/loki/api/v1/push
+	Labels        map[string]string // labels added to every stream, e.g. "app"; "host" defaults to the hostname
+	BatchSize     int               // number of lines that triggers a push, 100 if zero
+	BatchInterval time.Duration     // maximum time lines are held before a push, 1s if zero
+	Client        *http.Client      // http.DefaultClient if nil
+	OnError       func(error)       // called with errors from background pushes
+}
+
+// maxLokiPending bounds the lines a LokiWriter holds, whether Loki is
+// unreachable or lines are written faster than they are pushed; past it
+// the oldest are dropped.
+const maxLokiPending = 10000
+
+// LokiWriter batches log lines and pushes them to Grafana Loki. Each line
+// is labeled with the level of its record in addition to the configured
+// labels, and stamped with the time of the record. ANSI color codes are
+// stripped from the lines. Lines whose push failed on a network error, a
+// 429 or a 5xx response are kept and pushed again, ahead of newer ones,
+// with the next batch.
+type LokiWriter struct {
+	opts    LokiOptions
+	mu      sync.Mutex
+	pending []lokiEntry
+	pushMu  sync.Mutex // serializes pushes so lines reach Loki in order
+	flush   chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+	wg      sync.WaitGroup
+}
+
+type lokiEntry struct {
+	level string
+	ts    time.Time
+	line  string
+}
+
+// NewLokiWriter creates a LokiWriter and starts its background push loop.
+// Call Close to push the remaining lines and stop it.
+func NewLokiWriter(opts LokiOptions) *LokiWriter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	labels := map[string]string{}
+	if host, err := os.Hostname(); err == nil {
+		labels["host"] = host
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	opts.Labels = labels
+
+	w := &LokiWriter{
+		opts:  opts,
+		flush: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w
+}
+
+// NewLokiHandler returns an uncolored handler pushing records to Loki
+// through a new LokiWriter. Add it to ColorJSONHandler.Sinks to keep colored
+// output in the terminal.
+func NewLokiHandler(opts LokiOptions, hopts *slog.HandlerOptions) (*ColorJSONHandler, *LokiWriter) {
+	w := NewLokiWriter(opts)
+	h := NewHandler(w, hopts)
+	h.Colors = Colors{}
+	return h, w
+}
+
+// Write queues p as a line with level "info".
+func (w *LokiWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(slog.LevelInfo, p)
+}
+
+// WriteLevel queues p as a line labeled with level.
+func (w *LokiWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	return w.WriteRecord(level, time.Now(), p)
+}
+
+// WriteRecord queues p as a line labeled with level and stamped with t,
+// the time of its record, or the current time if t is zero.
+func (w *LokiWriter) WriteRecord(level slog.Level, t time.Time, p []byte) (int, error) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	line := string(bytes.TrimRight(stripANSI(p), "\r\n"))
+	w.mu.Lock()
+	w.pending = append(w.pending, lokiEntry{
+		level: strings.ToLower(level.String()),
+		ts:    t,
+		line:  line,
+	})
+	drop := w.trim()
+	full := len(w.pending) >= w.opts.BatchSize
+	w.mu.Unlock()
+	if drop > 0 && w.opts.OnError != nil {
+		w.opts.OnError(fmt.Errorf("colorjson: loki queue full; %d lines dropped", drop))
+	}
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Flush pushes all queued lines. If the push fails with an error worth
+// retrying, the lines are queued again.
+func (w *LokiWriter) Flush() error {
+	w.pushMu.Lock()
+	defer w.pushMu.Unlock()
+	w.mu.Lock()
+	entries := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+	err := w.push(entries)
+	if err != nil && retryable(err) {
+		w.mu.Lock()
+		w.pending = append(entries, w.pending...)
+		if drop := w.trim(); drop > 0 {
+			err = fmt.Errorf("%w; %d lines dropped", err, drop)
+		}
+		w.mu.Unlock()
+	}
+	return err
+}
+
+// trim drops the oldest pending lines past maxLokiPending and returns how
+// many it dropped. The caller holds w.mu.
+func (w *LokiWriter) trim() int {
+	drop := len(w.pending) - maxLokiPending
+	if drop <= 0 {
+		return 0
+	}
+	w.pending = append(w.pending[:0], w.pending[drop:]...)
+	return drop
+}
+
+// Close stops the background loop and pushes the remaining lines. Calling
+// it again only pushes the lines written since.
+func (w *LokiWriter) Close() error {
+	w.closed.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+	return w.Flush()
+}
+
+func (w *LokiWriter) loop() {
+	defer w.wg.Done()
+	t := time.NewTicker(w.opts.BatchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+		case <-w.flush:
+		}
+		if err := w.Flush(); err != nil && w.opts.OnError != nil {
+			w.opts.OnError(err)
+		}
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// push sends entries to Loki, one stream per level.
+func (w *LokiWriter) push(entries []lokiEntry) error {
+	streams := map[string]*lokiStream{}
+	var order []string
+	for _, e := range entries {
+		s, ok := streams[e.level]
+		if !ok {
+			labels := make(map[string]string, len(w.opts.Labels)+1)
+			for k, v := range w.opts.Labels {
+				labels[k] = v
+			}
+			labels["level"] = e.level
+			s = &lokiStream{Stream: labels}
+			streams[e.level] = s
+			order = append(order, e.level)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line})
+	}
+	body := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, level := range order {
+		body.Streams = append(body.Streams, streams[level])
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.opts.Client.Post(w.opts.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return &lokiError{err: err, retry: true}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &lokiError{
+			err:   fmt.Errorf("colorjson: loki push failed: %s: %s", resp.Status, bytes.TrimSpace(msg)),
+			retry: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		}
+	}
+	return nil
+}
+
+// lokiError is a failed push, which is retried unless Loki rejected the
+// lines themselves.
+type lokiError struct {
+	err   error
+	retry bool
+}
+
+func (e *lokiError) Error() string { return e.err.Error() }
+func (e *lokiError) Unwrap() error { return e.err }
+
+// retryable reports whether the push that failed with err is worth retrying.
+func retryable(err error) bool {
+	var le *lokiError
+	return errors.As(err, &le) && le.retry
+}
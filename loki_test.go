@@ -0,0 +1,149 @@
+package colorjson
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lokiServer is a Loki push endpoint answering with the queued statuses,
+// then 204, and recording the lines of the pushes it accepted.
+type lokiServer struct {
+	mu       sync.Mutex
+	statuses []int
+	pushes   int
+	streams  []lokiStream
+}
+
+func (s *lokiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushes++
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var body struct{ Streams []lokiStream }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.streams = append(s.streams, body.Streams...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lines returns the accepted lines by level.
+func (s *lokiServer) lines() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := map[string][]string{}
+	for _, st := range s.streams {
+		for _, v := range st.Values {
+			m[st.Stream["level"]] = append(m[st.Stream["level"]], v[1])
+		}
+	}
+	return m
+}
+
+func newLokiTest(t *testing.T, batch int, statuses ...int) (*lokiServer, *LokiWriter) {
+	s := &lokiServer{statuses: statuses}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	w := NewLokiWriter(LokiOptions{URL: srv.URL, BatchSize: batch, BatchInterval: time.Hour})
+	t.Cleanup(func() { w.Close() })
+	return s, w
+}
+
+func TestLokiRecordTime(t *testing.T) {
+	s, w := newLokiTest(t, 100)
+	h := NewHandler(w, nil)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := slog.NewRecord(ts, slog.LevelWarn, "m", 0)
+	if err := h.Handle(t.Context(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.streams) != 1 || len(s.streams[0].Values) != 1 {
+		t.Fatalf("got streams %v, want one line", s.streams)
+	}
+	if got, want := s.streams[0].Values[0][0], strconv.FormatInt(ts.UnixNano(), 10); got != want {
+		t.Errorf("line stamped %s, want the record time %s", got, want)
+	}
+	if got := s.streams[0].Stream["level"]; got != "warn" {
+		t.Errorf("level label %q, want warn", got)
+	}
+}
+
+func TestLokiBatching(t *testing.T) {
+	s, w := newLokiTest(t, 3)
+	l := slog.New(NewHandler(w, nil))
+	l.Info("a")
+	l.Error("b")
+	l.Info("c")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.lines()["info"]) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	got := s.lines()
+	if len(got["info"]) != 2 || len(got["error"]) != 1 {
+		t.Errorf("a full batch pushed %v, want 2 info lines and 1 error line", got)
+	}
+}
+
+func TestLokiRetry(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		statuses []int
+		kept     bool // lines pushed again after the first push failed
+	}{
+		{"unavailable", []int{http.StatusServiceUnavailable}, true},
+		{"rate limited", []int{http.StatusTooManyRequests}, true},
+		{"rejected", []int{http.StatusBadRequest}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newLokiTest(t, 100, tt.statuses...)
+			l := slog.New(NewHandler(w, nil))
+			l.Info("first")
+			if err := w.Flush(); err == nil {
+				t.Fatal("failed push returned no error")
+			}
+			l.Info("second")
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			want := 1
+			if tt.kept {
+				want = 2
+			}
+			if got := len(s.lines()["info"]); got != want {
+				t.Errorf("pushed %d lines, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestLokiPendingLimit(t *testing.T) {
+	var dropped int
+	w := &LokiWriter{
+		opts:  LokiOptions{BatchSize: 2 * maxLokiPending, OnError: func(error) { dropped++ }},
+		flush: make(chan struct{}, 1),
+	}
+	for i := range maxLokiPending + 5 {
+		w.Write([]byte(strconv.Itoa(i)))
+	}
+	if len(w.pending) != maxLokiPending || dropped != 5 {
+		t.Errorf("holding %d lines after %d drops, want %d after 5", len(w.pending), dropped, maxLokiPending)
+	}
+	if w.pending[0].line != "5" {
+		t.Errorf("oldest line %q, want 5", w.pending[0].line)
+	}
+}
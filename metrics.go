@@ -0,0 +1,86 @@
+package colorjson
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics counts the records logged by a handler, per level, and remembers
+// when the last error was logged. It implements expvar.Var so it can be
+// published directly, and its accessors make it easy to wrap in a
+// prometheus.Collector:
+//
+//	m := colorjson.NewMetrics()
+//	m.Publish("logs")
+//	h.Metrics = m
+type Metrics struct {
+	counts    [4]atomic.Uint64 // debug, info, warn, error
+	lastError atomic.Int64     // unix nanoseconds
+}
+
+// NewMetrics returns a new, zeroed Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// bucket maps a level to its counter: levels below Info count as debug,
+// below Warn as info, below Error as warn and the rest as error.
+func bucket(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return 0
+	case level < slog.LevelWarn:
+		return 1
+	case level < slog.LevelError:
+		return 2
+	}
+	return 3
+}
+
+// record counts a record logged at level at time t.
+func (m *Metrics) record(level slog.Level, t time.Time) {
+	m.counts[bucket(level)].Add(1)
+	if level >= slog.LevelError {
+		if t.IsZero() {
+			t = time.Now()
+		}
+		m.lastError.Store(t.UnixNano())
+	}
+}
+
+// Count returns the number of records logged in the same bucket as level,
+// one of debug, info, warn or error.
+func (m *Metrics) Count(level slog.Level) uint64 {
+	return m.counts[bucket(level)].Load()
+}
+
+// LastError returns the time of the last record logged at LevelError or
+// above, or the zero time if there has been none.
+func (m *Metrics) LastError() time.Time {
+	ns := m.lastError.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// String implements expvar.Var, returning the counts as a JSON object, e.g.
+// {"DEBUG": 0, "INFO": 12, "WARN": 1, "ERROR": 2, "last_error": "2024-..."}.
+func (m *Metrics) String() string {
+	last := "null"
+	if t := m.LastError(); !t.IsZero() {
+		last = `"` + t.UTC().Format(time.RFC3339Nano) + `"`
+	}
+	return fmt.Sprintf(`{"DEBUG": %d, "INFO": %d, "WARN": %d, "ERROR": %d, "last_error": %s}`,
+		m.counts[0].Load(), m.counts[1].Load(), m.counts[2].Load(), m.counts[3].Load(), last)
+}
+
+// Publish publishes the metrics under name with expvar, making them
+// available at /debug/vars. Like expvar.Publish, it panics if name is
+// already registered.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, m)
+}
@@ -0,0 +1,347 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// nonFiniteName returns the name of f, which is NaN or ±Inf, as written in
+// place of it: "NaN", "+Inf" or "-Inf".
+func nonFiniteName(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return "NaN"
+}
+
+// nonFinite is a NaN or ±Inf found in a value passed with slog.Any. It is
+// written as null, or as its name when asString is set, as the handler
+// writes such floats at the top level.
+type nonFinite struct {
+	f        float64
+	asString bool
+}
+
+func (n nonFinite) MarshalJSON() ([]byte, error) {
+	if !n.asString {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + nonFiniteName(n.f) + `"`), nil
+}
+
+// isNonFiniteError reports whether err is encoding/json rejecting a NaN or
+// ±Inf float.
+func isNonFiniteError(err error) bool {
+	uv, ok := err.(*json.UnsupportedValueError)
+	return ok && (uv.Str == "NaN" || uv.Str == "+Inf" || uv.Str == "-Inf")
+}
+
+// maxNonFiniteDepth stops replaceNonFinite in values referring back to
+// themselves, which encoding/json then reports as an error.
+const maxNonFiniteDepth = 100
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// replaceNonFinite returns a value that encoding/json encodes as it does
+// v, but with the NaN and ±Inf floats it rejects replaced by nonFinite
+// values. Values that encode themselves are kept as they are.
+func replaceNonFinite(v reflect.Value, asString bool, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxNonFiniteDepth {
+		return v.Interface()
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() {
+		if pt := reflect.PointerTo(t); pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+			return v.Addr().Interface()
+		}
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return nonFinite{f, asString}
+		}
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return replaceNonFinite(v.Elem(), asString, depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PointerTo(t.Elem()).Implements(jsonMarshalerType) && !reflect.PointerTo(t.Elem()).Implements(textMarshalerType) {
+			return v.Interface() // base64
+		}
+		fallthrough
+	case reflect.Array:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = replaceNonFinite(v.Index(i), asString, depth+1)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			k, ok := mapKey(iter.Key())
+			if !ok {
+				return v.Interface()
+			}
+			m[k] = replaceNonFinite(iter.Value(), asString, depth+1)
+		}
+		return m
+	case reflect.Struct:
+		var obj jsonObject
+		for _, f := range structFields(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && isZeroValue(fv)) {
+				continue
+			}
+			val := replaceNonFinite(fv, asString, depth+1)
+			if f.quoted {
+				val = quotedValue{val}
+			}
+			obj = append(obj, jsonMember{f.name, val})
+		}
+		return obj
+	}
+	return v.Interface()
+}
+
+// mapKey returns the JSON object key of the map key k, as encoding/json
+// resolves it.
+func mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", true
+		}
+		b, err := tm.MarshalText()
+		return string(b), err == nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// jsonField is a struct field encoding/json writes, found by structFields.
+type jsonField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+}
+
+// structFields returns the fields encoding/json writes for struct type t,
+// in its order and with its rules for embedded structs: the shallowest
+// field with a name wins, a tagged one if several are equally shallow, and
+// none if that leaves more than one.
+func structFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	var walk func(t reflect.Type, index []int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			ft := sf.Type
+			if sf.Anonymous {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !sf.IsExported() && ft.Kind() != reflect.Struct {
+					continue
+				}
+			} else if !sf.IsExported() {
+				continue
+			}
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			idx := append(slices.Clip(index), i)
+			if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+				walk(ft, idx, visited)
+				continue
+			}
+			if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			f := jsonField{name: name, index: idx, tagged: name != ""}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for opt := range strings.SplitSeq(opts, ",") {
+				switch opt {
+				case "omitempty":
+					f.omitEmpty = true
+				case "omitzero":
+					f.omitZero = true
+				case "string":
+					switch ft.Kind() {
+					case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64, reflect.String:
+						f.quoted = true
+					}
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(t, nil, map[reflect.Type]bool{})
+
+	var kept []jsonField
+	for _, f := range fields {
+		dominant := true
+		for _, g := range fields {
+			if g.name != f.name || len(g.index) > len(f.index) || slices.Equal(g.index, f.index) {
+				continue
+			}
+			if len(g.index) < len(f.index) || g.tagged == f.tagged || g.tagged {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// fieldByIndex returns the field of v at index, or false if it is inside
+// a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty for the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isZeroValue reports whether v is zero for the omitzero option, using its
+// IsZero method if it has one.
+func isZeroValue(v reflect.Value) bool {
+	if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return true
+		}
+		return z.IsZero()
+	}
+	return v.IsZero()
+}
+
+// jsonObject is a struct rebuilt by replaceNonFinite, written with its
+// members in order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key string
+	val any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	out := []byte{'{'}
+	for i, m := range o {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		key, err := marshalUnescaped(m.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := marshalUnescaped(m.val)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(append(out, key...), ':'), val...)
+	}
+	return append(out, '}'), nil
+}
+
+// quotedValue is a field with the "string" option, written as a JSON
+// string holding its JSON encoding.
+type quotedValue struct{ val any }
+
+func (q quotedValue) MarshalJSON() ([]byte, error) {
+	switch v := q.val.(type) {
+	case nil:
+		return []byte("null"), nil
+	case nonFinite:
+		return v.MarshalJSON()
+	}
+	data, err := marshalUnescaped(q.val)
+	if err != nil {
+		return nil, err
+	}
+	return marshalUnescaped(string(data))
+}
+
+// marshalUnescaped encodes v without escaping HTML, which is left to the
+// encoder of the whole value.
+func marshalUnescaped(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
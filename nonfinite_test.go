@@ -0,0 +1,102 @@
+package colorjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type embedded struct {
+	A float64
+	B string `json:"b"`
+}
+
+type Embedded struct {
+	A int
+	C float64
+}
+
+type fields struct {
+	embedded
+	*Embedded
+	C     float64            `json:"c,omitempty"`
+	D     *float64           `json:",string"`
+	E     float64            `json:"-"`
+	T     time.Time          `json:"t,omitzero"`
+	IP    netip.Addr         // a TextMarshaler
+	Raw   json.RawMessage    // a Marshaler
+	Bytes []byte             // base64
+	Keys  map[int]float64    // keys sorted as strings
+	Addrs map[netip.Addr]any // TextMarshaler keys
+	HTML  string
+	hide  float64
+}
+
+func TestReplaceNonFinite(t *testing.T) {
+	d := 2.5
+	ip := netip.MustParseAddr("10.0.0.1")
+	// values without NaN and ±Inf are encoded as encoding/json does
+	for _, v := range []any{
+		[]float64{1, 2.5},
+		[3]any{1, "x", nil},
+		map[string]any{"x": []any{1.5, map[string]int{"y": 2}}},
+		fields{},
+		fields{
+			embedded{1, "b"}, &Embedded{2, 3}, 4, &d, 5, time.Unix(0, 0).UTC(),
+			ip, json.RawMessage(`{"raw":true}`), []byte("hi"),
+			map[int]float64{10: 1, 9: 2}, map[netip.Addr]any{ip: 1}, "<b>&</b>", 6,
+		},
+		&struct{ P *struct{ X float32 } }{&struct{ X float32 }{1.5}},
+	} {
+		for _, escape := range []bool{false, true} {
+			var want, got bytes.Buffer
+			enc := json.NewEncoder(&want)
+			enc.SetEscapeHTML(escape)
+			if err := enc.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			enc = json.NewEncoder(&got)
+			enc.SetEscapeHTML(escape)
+			if err := enc.Encode(replaceNonFinite(reflect.ValueOf(v), false, 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("%T: got %s want %s", v, got.String(), want.String())
+			}
+		}
+	}
+}
+
+func TestNonFiniteNested(t *testing.T) {
+	inf := math.Inf(-1)
+	for _, tt := range []struct {
+		format   Format
+		asString bool
+		want     string
+	}{
+		{FormatJSON, false, `"x":null,"list":[1,null],"obj":{"A":null,"b":"","m":{"k":null},"D":null}`},
+		{FormatJSON, true, `"x":"NaN","list":[1,"NaN"],"obj":{"A":"+Inf","b":"","m":{"k":"NaN"},"D":"-Inf"}`},
+		{FormatLogfmt, false, `x=NaN list="[1,\"NaN\"]" obj="{\"A\":\"+Inf\",\"b\":\"\",\"m\":{\"k\":\"NaN\"},\"D\":\"-Inf\"}"`},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime})
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		h.Format, h.NonFiniteAsString = tt.format, tt.asString
+		obj := struct {
+			A float64
+			B string         `json:"b"`
+			M map[string]any `json:"m"`
+			D *float64       `json:",string"`
+		}{math.Inf(1), "", map[string]any{"k": math.NaN()}, &inf}
+		slog.New(h).Info("m", "x", math.NaN(), "list", []float64{1, math.NaN()}, "obj", obj)
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("format %d, strings %v: got %s want %s", tt.format, tt.asString, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package colorjson
+
+import (
+	"context"
+	"log/slog"
+)
+
+// os_log types, from <os/log.h>
+const (
+	osLogTypeDefault = 0x00
+	osLogTypeDebug   = 0x02
+	osLogTypeError   = 0x10
+	osLogTypeFault   = 0x11
+)
+
+// OSLogHandler writes records to the macOS unified logging system, where
+// they show up in Console.app and `log stream` under their subsystem and
+// category. The message is followed by the attrs in logfmt; the level maps
+// to the os_log type. Add it to ColorJSONHandler.Sinks to keep colored
+// console output as well. It requires cgo.
+type OSLogHandler struct {
+	h   *ColorJSONHandler // tracks groups, attrs and options
+	log *osLog
+}
+
+// NewOSLogHandler returns a handler logging with os_log under subsystem,
+// e.g. "com.example.myapp", and category. It fails on systems other than
+// macOS and in binaries built without cgo.
+func NewOSLogHandler(subsystem, category string, opts *slog.HandlerOptions) (*OSLogHandler, error) {
+	log, err := newOSLog(subsystem, category)
+	if err != nil {
+		return nil, err
+	}
+	return &OSLogHandler{h: NewHandler(nil, opts), log: log}, nil
+}
+
+// Enabled implements slog.Handler.
+func (o *OSLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return o.h.enabled(level)
+}
+
+// Handle implements slog.Handler.
+func (o *OSLogHandler) Handle(_ context.Context, r slog.Record) error {
+	e := encoder{format: FormatLogfmt, sep: "."}
+	e.beginRecord()
+	e.buf = append(e.buf, r.Message...)
+	e.empty = r.Message == ""
+	for _, a := range o.h.collect(r) {
+		e.attr(a)
+	}
+	o.log.write(osLogType(r.Level), string(e.buf))
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (o *OSLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	o2 := *o
+	o2.h = o.h.WithAttrs(attrs).(*ColorJSONHandler)
+	return &o2
+}
+
+// WithGroup implements slog.Handler.
+func (o *OSLogHandler) WithGroup(name string) slog.Handler {
+	o2 := *o
+	o2.h = o.h.WithGroup(name).(*ColorJSONHandler)
+	return &o2
+}
+
+// osLogType returns the os_log type of a level. INFO records use the default
+// type, as os_log's info type is not kept unless configured.
+func osLogType(l slog.Level) uint8 {
+	switch {
+	case l >= slog.LevelError+4:
+		return osLogTypeFault
+	case l >= slog.LevelError:
+		return osLogTypeError
+	case l >= slog.LevelInfo:
+		return osLogTypeDefault
+	default:
+		return osLogTypeDebug
+	}
+}
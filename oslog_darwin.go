@@ -0,0 +1,34 @@
+//go:build darwin && cgo
+
+package colorjson
+
+/*
+#include <os/log.h>
+#include <stdlib.h>
+
+static void colorjson_os_log(os_log_t log, os_log_type_t type, const char *msg) {
+	os_log_with_type(log, type, "%{public}s", msg);
+}
+*/
+import "C"
+
+import "unsafe"
+
+// osLog is an os_log_t created with os_log_create.
+type osLog struct {
+	log C.os_log_t
+}
+
+func newOSLog(subsystem, category string) (*osLog, error) {
+	s, c := C.CString(subsystem), C.CString(category)
+	defer C.free(unsafe.Pointer(s))
+	defer C.free(unsafe.Pointer(c))
+	return &osLog{log: C.os_log_create(s, c)}, nil
+}
+
+// write logs msg, which is public so it is not redacted in Console.app.
+func (l *osLog) write(typ uint8, msg string) {
+	m := C.CString(msg)
+	defer C.free(unsafe.Pointer(m))
+	C.colorjson_os_log(l.log, C.os_log_type_t(typ), m)
+}
@@ -0,0 +1,13 @@
+//go:build !darwin || !cgo
+
+package colorjson
+
+import "errors"
+
+type osLog struct{}
+
+func newOSLog(subsystem, category string) (*osLog, error) {
+	return nil, errors.New("colorjson: os_log is only supported on darwin with cgo")
+}
+
+func (*osLog) write(uint8, string) {}
@@ -0,0 +1,224 @@
+package colorjson
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ColorProfile is the range of colors a terminal supports. Escape sequences
+// using more colors than the handler's profile are converted to the nearest
+// supported color before being written.
+type ColorProfile int
+
+const (
+	ProfileTrueColor ColorProfile = iota // 24-bit colors, written unchanged (default)
+	ProfileANSI256                       // the 256-color palette
+	ProfileANSI16                        // the 16 basic colors
+	ProfileNone                          // no escape sequences at all
+)
+
+// DetectColorProfile returns the color profile of the terminal from the
+// TERM and COLORTERM environment variables, or ProfileNone if NO_COLOR is
+// set to anything but "" (see https://no-color.org). An unset TERM is
+// assumed to support all colors.
+func DetectColorProfile() ColorProfile {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return ProfileNone
+	}
+	return termProfile()
+}
+
+// termProfile returns the color profile named by TERM and COLORTERM, the
+// 16 basic colors for TERM=dumb.
+func termProfile() ColorProfile {
+	term := os.Getenv("TERM")
+	switch ct := os.Getenv("COLORTERM"); {
+	case ct == "truecolor" || ct == "24bit" || os.Getenv("WT_SESSION") != "":
+		return ProfileTrueColor
+	case term == "" || strings.Contains(term, "truecolor") || strings.Contains(term, "direct"):
+		return ProfileTrueColor
+	case strings.Contains(term, "256color"):
+		return ProfileANSI256
+	}
+	return ProfileANSI16
+}
+
+// detectProfile returns the color profile of output to w: ProfileNone if
+// w is a file but not a terminal, e.g. a pipe or a regular file, and
+// otherwise that of DetectColorProfile. Other writers, such as buffers,
+// are assumed to end up on the terminal.
+func detectProfile(w io.Writer) ColorProfile {
+	if f, ok := w.(*os.File); ok && !isTerminal(f) {
+		return ProfileNone
+	}
+	return DetectColorProfile()
+}
+
+// isTerminal reports whether f is a terminal, or another character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// forceColorEnv, set to anything but "", "0" or "false", enables ForceColor
+// on new handlers, unless NO_COLOR is set too.
+const forceColorEnv = "COLORJSON_FORCE"
+
+// envForceColor reports whether forceColorEnv asks for colors and NO_COLOR
+// doesn't forbid them.
+func envForceColor() bool {
+	v := os.Getenv(forceColorEnv)
+	return v != "" && v != "0" && !strings.EqualFold(v, "false") && os.Getenv("NO_COLOR") == ""
+}
+
+// forcedTermProfile is the profile colors are forced in, looked up once.
+var forcedTermProfile = sync.OnceValue(termProfile)
+
+// forcedProfile returns profile, or in place of ProfileNone when colors are
+// forced, the profile TERM and COLORTERM name.
+func forcedProfile(profile ColorProfile, force bool) ColorProfile {
+	if force && profile == ProfileNone {
+		return forcedTermProfile()
+	}
+	return profile
+}
+
+// downgradeANSI rewrites the SGR sequences of b to only use colors of the
+// profile.
+func downgradeANSI(b []byte, profile ColorProfile) []byte {
+	switch profile {
+	case ProfileTrueColor:
+		return b
+	case ProfileNone:
+		// records written without colors have nothing to strip
+		if bytes.IndexByte(b, '\033') < 0 {
+			return b
+		}
+		return stripANSI(b)
+	}
+	// only 256-color and 24-bit colors need converting
+	if !bytes.Contains(b, []byte("8;5;")) && !bytes.Contains(b, []byte("8;2;")) {
+		return b
+	}
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\033' || i+1 >= len(b) || b[i+1] != '[' {
+			out = append(out, b[i])
+			continue
+		}
+		end := i + 2
+		for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+			end++
+		}
+		if end == len(b) || b[end] != 'm' {
+			out = append(out, b[i:min(end+1, len(b))]...)
+			i = end
+			continue
+		}
+		out = append(out, "\033["...)
+		out = appendSGR(out, string(b[i+2:end]), profile)
+		out = append(out, 'm')
+		i = end
+	}
+	return out
+}
+
+// appendSGR appends the parameters of an SGR sequence with 256 and 24-bit
+// colors converted to the profile.
+func appendSGR(b []byte, params string, profile ColorProfile) []byte {
+	ps := strings.Split(params, ";")
+	for i := 0; i < len(ps); i++ {
+		if i > 0 {
+			b = append(b, ';')
+		}
+		if (ps[i] != "38" && ps[i] != "48") || i+1 >= len(ps) {
+			b = append(b, ps[i]...)
+			continue
+		}
+		base := 30
+		if ps[i] == "48" {
+			base = 40
+		}
+		var rgb [3]uint8
+		switch {
+		case ps[i+1] == "5" && i+2 < len(ps):
+			n, err := strconv.ParseUint(ps[i+2], 10, 8)
+			if err != nil {
+				b = append(b, ps[i]...)
+				continue
+			}
+			i += 2
+			if profile == ProfileANSI256 {
+				b = append(b, ps[i-2]+";5;"+ps[i]...)
+				continue
+			}
+			if n < 16 {
+				b = appendBasic(b, base, int(n))
+				continue
+			}
+			rgb = ansi256RGB(uint8(n))
+		case ps[i+1] == "2" && i+4 < len(ps):
+			for j := range rgb {
+				n, _ := strconv.ParseUint(ps[i+2+j], 10, 8)
+				rgb[j] = uint8(n)
+			}
+			i += 4
+			if profile == ProfileANSI256 {
+				b = append(b, strconv.Itoa(base+8)+";5;"...)
+				b = strconv.AppendInt(b, int64(nearest256(rgb)), 10)
+				continue
+			}
+		default:
+			b = append(b, ps[i]...)
+			continue
+		}
+		b = appendBasic(b, base, nearestBasic(rgb))
+	}
+	return b
+}
+
+// appendBasic appends the SGR parameter of basic color n for base 30
+// (foreground) or 40 (background).
+func appendBasic(b []byte, base, n int) []byte {
+	if n >= 8 {
+		base += 60
+	}
+	return strconv.AppendInt(b, int64(base+n%8), 10)
+}
+
+// nearestBasic returns the index of the basic color closest to c.
+func nearestBasic(c [3]uint8) int {
+	best, bestDist := 0, -1
+	for i, p := range ansiPalette {
+		if d := colorDist(c, p); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// nearest256 returns the index of the color of the 256-color palette,
+// excluding the basic colors, closest to c.
+func nearest256(c [3]uint8) int {
+	best, bestDist := 16, -1
+	for i := 16; i < 256; i++ {
+		if d := colorDist(c, ansi256RGB(uint8(i))); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// colorDist is the squared euclidean distance between two colors.
+func colorDist(a, b [3]uint8) int {
+	d := 0
+	for i := range a {
+		x := int(a[i]) - int(b[i])
+		d += x * x
+	}
+	return d
+}
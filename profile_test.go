@@ -0,0 +1,41 @@
+package colorjson
+
+import "testing"
+
+func TestDetectColorProfile(t *testing.T) {
+	for _, tt := range []struct {
+		term, colorterm, noColor, force string
+		detected, forced                ColorProfile
+		forceEnv                        bool
+	}{
+		{"xterm-256color", "", "", "", ProfileANSI256, ProfileANSI256, false},
+		{"xterm", "truecolor", "", "1", ProfileTrueColor, ProfileTrueColor, true},
+		{"xterm", "", "", "true", ProfileANSI16, ProfileANSI16, true},
+		{"dumb", "", "", "1", ProfileNone, ProfileANSI16, true},
+		{"xterm-256color", "", "1", "1", ProfileNone, ProfileANSI256, false},
+		{"", "", "", "0", ProfileTrueColor, ProfileTrueColor, false},
+		{"screen", "", "", "false", ProfileANSI16, ProfileANSI16, false},
+	} {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("COLORTERM", tt.colorterm)
+		t.Setenv("WT_SESSION", "")
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv(forceColorEnv, tt.force)
+		if got := DetectColorProfile(); got != tt.detected {
+			t.Errorf("TERM=%q COLORTERM=%q NO_COLOR=%q: detected %d, want %d", tt.term, tt.colorterm, tt.noColor, got, tt.detected)
+		}
+		// forcedProfile looks the profile up once, so termProfile stands in
+		if got := termProfile(); got != tt.forced {
+			t.Errorf("TERM=%q COLORTERM=%q: forced %d, want %d", tt.term, tt.colorterm, got, tt.forced)
+		}
+		if got := envForceColor(); got != tt.forceEnv {
+			t.Errorf("%s=%q NO_COLOR=%q: force %v, want %v", forceColorEnv, tt.force, tt.noColor, got, tt.forceEnv)
+		}
+	}
+	if forcedProfile(ProfileNone, false) != ProfileNone || forcedProfile(ProfileANSI16, true) != ProfileANSI16 {
+		t.Error("forcedProfile changed a profile that isn't forced")
+	}
+	if forcedProfile(ProfileNone, true) == ProfileNone {
+		t.Error("forcedProfile left forced colors off")
+	}
+}
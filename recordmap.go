@@ -0,0 +1,88 @@
+package colorjson
+
+import "log/slog"
+
+// RecordToMap returns the structure ColorJSONHandler writes for r as nested
+// maps, for sinks and tests that need the fields rather than the text.
+// attrs are those added with WithAttrs, at the top level, and groups those
+// added with WithGroup, which nest the record's attrs.
+//
+// The rules of the encoder apply: time is left out when zero, LogValuers are
+// resolved, empty attrs are dropped, groups with an empty key are inlined
+// and groups with no attrs are left out. Values are those returned by
+// slog.Value.Any, e.g. int64 for any signed integer, and a later attr
+// replaces an earlier one with the same key.
+func RecordToMap(r slog.Record, attrs []slog.Attr, groups []string) map[string]any {
+	m := make(map[string]any, 3+len(attrs)+r.NumAttrs())
+	if !r.Time.IsZero() {
+		m[slog.TimeKey] = r.Time
+	}
+	m[slog.LevelKey] = r.Level
+	m[slog.MessageKey] = r.Message
+	for _, a := range attrs {
+		addMapAttr(m, a)
+	}
+
+	rec := make(map[string]any, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		addMapAttr(rec, a)
+		return true
+	})
+	pruneMap(rec)
+	if len(rec) == 0 {
+		pruneMap(m)
+		return m
+	}
+	inner := m
+	for _, g := range groups {
+		sub, ok := inner[g].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			inner[g] = sub
+		}
+		inner = sub
+	}
+	for k, v := range rec {
+		inner[k] = v
+	}
+	pruneMap(m)
+	return m
+}
+
+// addMapAttr adds a to m, resolved.
+func addMapAttr(m map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = a.Value.Any()
+		return
+	}
+	if a.Key == "" {
+		for _, ga := range a.Value.Group() {
+			addMapAttr(m, ga)
+		}
+		return
+	}
+	sub, ok := m[a.Key].(map[string]any)
+	if !ok {
+		sub = map[string]any{}
+		m[a.Key] = sub
+	}
+	for _, ga := range a.Value.Group() {
+		addMapAttr(sub, ga)
+	}
+}
+
+// pruneMap removes the groups of m left empty.
+func pruneMap(m map[string]any) {
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			pruneMap(sub)
+			if len(sub) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
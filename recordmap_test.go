@@ -0,0 +1,43 @@
+package colorjson
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordToMap(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	record := func(tm time.Time, attrs ...slog.Attr) slog.Record {
+		r := slog.NewRecord(tm, slog.LevelWarn, "m", 0)
+		r.AddAttrs(attrs...)
+		return r
+	}
+	for _, tt := range []struct {
+		name   string
+		r      slog.Record
+		attrs  []slog.Attr
+		groups []string
+		want   map[string]any
+	}{
+		{"plain", record(ts, slog.Int("n", 1), slog.String("s", "x")), nil, nil,
+			map[string]any{"time": ts, "level": slog.LevelWarn, "msg": "m", "n": int64(1), "s": "x"}},
+		{"zero time", record(time.Time{}, slog.Bool("ok", true)), nil, nil,
+			map[string]any{"level": slog.LevelWarn, "msg": "m", "ok": true}},
+		{"handler attrs and groups", record(time.Time{}, slog.Int("n", 1)), []slog.Attr{slog.String("svc", "api")}, []string{"g", "h"},
+			map[string]any{"level": slog.LevelWarn, "msg": "m", "svc": "api", "g": map[string]any{"h": map[string]any{"n": int64(1)}}}},
+		{"groups without record attrs", record(time.Time{}), nil, []string{"g"},
+			map[string]any{"level": slog.LevelWarn, "msg": "m"}},
+		{"resolved, inlined and pruned", record(time.Time{},
+			slog.Any("pw", secret("hunter2")), slog.Group("", slog.Int("inline", 1)),
+			slog.Group("empty"), slog.Attr{}, slog.Group("user", slog.String("id", "u1"))), nil, nil,
+			map[string]any{"level": slog.LevelWarn, "msg": "m", "pw": "***", "inline": int64(1), "user": map[string]any{"id": "u1"}}},
+		{"later attr wins", record(time.Time{}, slog.Int("n", 1), slog.Int("n", 2)), nil, nil,
+			map[string]any{"level": slog.LevelWarn, "msg": "m", "n": int64(2)}},
+	} {
+		if got := RecordToMap(tt.r, tt.attrs, tt.groups); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package colorjson
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// RecoverAndLog recovers from a panic and logs it at ERROR with the panic
+// value and the stack of the panicking goroutine. It must be deferred directly:
+//
+//	defer colorjson.RecoverAndLog(logger, false)
+//
+// When repanic is true the panic is resumed after it has been logged.
+func RecoverAndLog(logger *slog.Logger, repanic bool) {
+	if v := recover(); v != nil {
+		logPanic(logger, v)
+		if repanic {
+			panic(v)
+		}
+	}
+}
+
+// RecoverMiddleware returns HTTP middleware that recovers panics raised by
+// the next handler, logs them and responds with 500 Internal Server Error.
+// When repanic is true the panic is resumed after it has been logged.
+func RecoverMiddleware(logger *slog.Logger, repanic bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// net/http uses ErrAbortHandler to abort a response silently
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logPanic(logger, v, slog.String("method", r.Method), slog.String("path", r.URL.Path))
+				if repanic {
+					panic(v)
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logPanic logs a recovered panic value. It is called from the deferred
+// function, so the stack starts at the point of the panic.
+func logPanic(logger *slog.Logger, v any, attrs ...slog.Attr) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	val := slog.AnyValue(v)
+	if _, ok := v.(error); !ok && val.Kind() == slog.KindAny {
+		val = slog.StringValue(fmt.Sprint(v))
+	}
+	attrs = append(attrs,
+		slog.Attr{Key: "panic", Value: val},
+		slog.Any("stack", callerStack(2, 0)),
+	)
+	logger.LogAttrs(context.Background(), slog.LevelError, "panic recovered", attrs...)
+}
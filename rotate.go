@@ -0,0 +1,271 @@
+package colorjson
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp inserted into the names of rotated files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingWriter is an io.WriteCloser writing to a file that is rotated
+// once it reaches MaxSize bytes or, if set, once it is older than Interval.
+// Rotated files are renamed to
+// name-<timestamp>.ext, or name-<timestamp>-<n>.ext if rotated more than
+// once in a millisecond, optionally gzip compressed, and removed once there
+// are more than MaxBackups of them or they are older than MaxAge.
+type RotatingWriter struct {
+	Filename   string        // file to write to
+	MaxSize    int64         // size in bytes that triggers a rotation, 100 MiB if zero
+	Interval   time.Duration // age of the current file that triggers a rotation, disabled if zero
+	MaxAge     time.Duration // rotated files older than this are removed, kept forever if zero
+	MaxBackups int           // number of rotated files to keep, all if zero
+	Compress   bool          // gzip rotated files
+	OnError    func(error)   // called with errors from compressing and pruning rotated files
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time      // when the current file was opened
+	wg     sync.WaitGroup // pending compressions
+	bg     sync.Mutex     // serializes compressions and pruning
+	last   string         // timestamp of the last rotation
+	seq    int            // sequence number of the last rotation in last
+	bgErr  error          // first error from compressing or pruning, if OnError is nil
+}
+
+// Write implements io.Writer. The file is opened on the first write.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	expired := w.Interval > 0 && time.Since(w.opened) >= w.Interval
+	if w.size > 0 && (w.size+int64(len(p)) > w.maxSize() || expired) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file and starts a new one.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// Close closes the current file and waits for pending compressions. If
+// OnError is nil, it also returns the first error from compressing or
+// pruning rotated files since the last Close.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.wg.Wait()
+	err = errors.Join(err, w.bgErr)
+	w.bgErr = nil
+	return err
+}
+
+func (w *RotatingWriter) maxSize() int64 {
+	if w.MaxSize <= 0 {
+		return 100 << 20
+	}
+	return w.MaxSize
+}
+
+func (w *RotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.Filename), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// the modification time of an existing file is that of its last
+	// write, not its start, so Interval counts from when it is opened
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	backup := w.backupName(time.Now())
+	err := os.Rename(w.Filename, backup)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	compress := w.Compress && err == nil
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		// pruning waits for compressions, which would otherwise race it
+		// for the files they are writing and removing
+		w.bg.Lock()
+		defer w.bg.Unlock()
+		var err error
+		if compress {
+			err = compressFile(backup)
+		}
+		if err = errors.Join(err, w.prune()); err == nil {
+			return
+		}
+		if w.OnError != nil {
+			w.OnError(err)
+		} else if w.bgErr == nil {
+			w.bgErr = err
+		}
+	}()
+	return nil
+}
+
+// backupName returns the name of a file rotated at t, adding a sequence
+// number if the last rotation had the same timestamp or a file of that
+// name exists, compressed or not. The number keeps growing within the
+// timestamp, so names freed by pruning are not reused out of order.
+func (w *RotatingWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.Filename)
+	stamp := t.Format(backupTimeFormat)
+	if stamp != w.last {
+		w.last, w.seq = stamp, 0
+	} else {
+		w.seq++
+	}
+	base := strings.TrimSuffix(w.Filename, ext) + "-" + stamp
+	for {
+		name := base + ext
+		if w.seq > 0 {
+			name = fmt.Sprintf("%s-%d%s", base, w.seq, ext)
+		}
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		w.seq++
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge.
+func (w *RotatingWriter) prune() error {
+	if w.MaxBackups <= 0 && w.MaxAge <= 0 {
+		return nil
+	}
+	ext := filepath.Ext(w.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(w.Filename, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(w.Filename))
+	if err != nil {
+		return err
+	}
+	type backup struct {
+		path string
+		t    time.Time
+		seq  int
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		seq := 0
+		if rest := stamp[len(backupTimeFormat):]; rest != "" {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(rest, "-")); err != nil || rest[0] != '-' {
+				continue
+			}
+		}
+		backups = append(backups, backup{filepath.Join(filepath.Dir(w.Filename), name), t, seq})
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].t.Equal(backups[j].t) {
+			return backups[i].t.After(backups[j].t)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	var errs []error
+	for i, b := range backups {
+		if (w.MaxBackups > 0 && i >= w.MaxBackups) || (w.MaxAge > 0 && time.Since(b.t) > w.MaxAge) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile gzips path to path.gz and removes the original.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
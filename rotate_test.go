@@ -0,0 +1,111 @@
+package colorjson
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateBackupName(t *testing.T) {
+	dir := t.TempDir()
+	w := &RotatingWriter{Filename: filepath.Join(dir, "app.log")}
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, tt := range []struct {
+		t    time.Time
+		want string
+	}{
+		{t0, "app-2024-05-01T12-00-00.000.log"},
+		{t0, "app-2024-05-01T12-00-00.000-1.log"},
+		{t0, "app-2024-05-01T12-00-00.000-2.log"},
+		{t0.Add(time.Millisecond), "app-2024-05-01T12-00-00.001.log"},
+	} {
+		if got := filepath.Base(w.backupName(tt.t)); got != tt.want {
+			t.Errorf("backupName(%s) = %s, want %s", tt.t.Format(backupTimeFormat), got, tt.want)
+		}
+	}
+
+	// a name taken by a compressed file is skipped
+	os.WriteFile(filepath.Join(dir, "app-2024-05-01T12-00-01.000.log.gz"), nil, 0o644)
+	if got := filepath.Base(w.backupName(t0.Add(time.Second))); got != "app-2024-05-01T12-00-01.000-1.log" {
+		t.Errorf("backupName over an existing file = %s", got)
+	}
+}
+
+// backups returns the rotated files next to name.
+func backups(t *testing.T, name string) []string {
+	t.Helper()
+	ext := filepath.Ext(name)
+	matches, err := filepath.Glob(strings.TrimSuffix(name, ext) + "-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestRotateMaxBackups(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := &RotatingWriter{Filename: name, MaxSize: 10, MaxBackups: 2}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		// each line fills the file past MaxSize, so the next one rotates it
+		if _, err := w.Write([]byte(strings.Repeat(line, 3))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := backups(t, name)
+	if len(files) != 2 {
+		t.Fatalf("kept %v, want 2 backups", files)
+	}
+	var kept []string
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		kept = append(kept, strings.Fields(string(data))[0])
+	}
+	if slices.Sort(kept); !slices.Equal(kept, []string{"four", "three"}) {
+		t.Errorf("backups hold %v, want the two newest, three and four", kept)
+	}
+	if data, _ := os.ReadFile(name); !strings.HasPrefix(string(data), "five") {
+		t.Errorf("current file holds %q, want five", data)
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	var errs []error
+	w := &RotatingWriter{Filename: name, Compress: true, OnError: func(err error) { errs = append(errs, err) }}
+	w.Write([]byte("rotated\n"))
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("current\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	files := backups(t, name)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".log.gz") {
+		t.Fatalf("backups %v, want one .log.gz", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil || string(data) != "rotated\n" {
+		t.Errorf("decompressed %q, %v; want the rotated line", data, err)
+	}
+}
@@ -0,0 +1,131 @@
+package colorjson
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// ValueRule paints the values of the attrs with key Key that Match
+// reports true for in Color, giving them a semantic color, e.g.
+//
+//	[]ValueRule{
+//		{Key: "status", Match: AtLeast(500), Color: RedColor},
+//		{Key: "status", Match: AtLeast(400), Color: YellowColor},
+//	}
+//
+// Key is an attr key, matched in any group, or the dotted path of a
+// grouped attr, e.g. "http.status". The first matching rule wins.
+type ValueRule struct {
+	Key   string
+	Match func(slog.Value) bool
+	Color TerminalColor
+}
+
+// AtLeast matches the numbers greater than or equal to n. Durations are
+// compared in nanoseconds and StatusCode values by their code.
+func AtLeast(n float64) func(slog.Value) bool {
+	return func(v slog.Value) bool {
+		f, ok := number(v)
+		return ok && f >= n
+	}
+}
+
+// Below matches the numbers less than n, see AtLeast.
+func Below(n float64) func(slog.Value) bool {
+	return func(v slog.Value) bool {
+		f, ok := number(v)
+		return ok && f < n
+	}
+}
+
+// Equals matches the values whose string form is s, e.g. Equals("failed")
+// or Equals("true").
+func Equals(s string) func(slog.Value) bool {
+	return func(v slog.Value) bool {
+		return v.String() == s
+	}
+}
+
+// number returns v as a float64 if it is a number.
+func number(v slog.Value) (float64, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return float64(v.Int64()), true
+	case slog.KindUint64:
+		return float64(v.Uint64()), true
+	case slog.KindFloat64:
+		return v.Float64(), true
+	case slog.KindDuration:
+		return float64(v.Duration()), true
+	case slog.KindAny:
+		if s, ok := v.Any().(StatusCode); ok {
+			return float64(s.Code), true
+		}
+	}
+	return 0, false
+}
+
+// ruleColor returns the color of the first of rules matching the attr
+// with key under the groups of path, and false if none does.
+func ruleColor(rules []ValueRule, path []string, key string, v slog.Value) (TerminalColor, bool) {
+	for _, r := range rules {
+		if r.Match == nil || r.Color == "" {
+			continue
+		}
+		if r.Key != key && !isPath(r.Key, path, key) {
+			continue
+		}
+		if r.Match(v) {
+			return r.Color, true
+		}
+	}
+	return "", false
+}
+
+// overThreshold reports whether v is a number above the limit in
+// thresholds of the attr with key under the groups of path. The limit of
+// the dotted path takes precedence over that of the key.
+func overThreshold(thresholds map[string]float64, path []string, key string, v slog.Value) bool {
+	if len(thresholds) == 0 {
+		return false
+	}
+	limit, ok := 0.0, false
+	if len(path) > 0 {
+		for k, l := range thresholds {
+			if isPath(k, path, key) {
+				limit, ok = l, true
+				break
+			}
+		}
+	}
+	if !ok {
+		if limit, ok = thresholds[key]; !ok {
+			return false
+		}
+	}
+	f, isNum := number(v)
+	return isNum && f > limit
+}
+
+// isPath reports whether dotted is the path of key under the groups of
+// path, e.g. "http.status", without joining them.
+func isPath(dotted string, path []string, key string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for _, p := range path {
+		rest, ok := strings.CutPrefix(dotted, p)
+		if !ok || !strings.HasPrefix(rest, ".") {
+			return false
+		}
+		dotted = rest[1:]
+	}
+	return dotted == key
+}
+
+// paintValue repaints the value written to e.buf from start on in c.
+func (e *encoder) paintValue(c TerminalColor, start int) {
+	plain := stripANSI(e.buf[start:])
+	e.buf = e.buf[:start]
+	e.colored(c, plain)
+}
@@ -0,0 +1,54 @@
+package colorjson
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueRules(t *testing.T) {
+	red, yellow, blue := FgRGB(255, 0, 0), FgRGB(255, 255, 0), FgRGB(0, 0, 255)
+	rules := []ValueRule{
+		{Key: "status", Match: AtLeast(500), Color: red},
+		{Key: "status", Match: AtLeast(400), Color: yellow},
+		{Key: "http.took", Match: AtLeast(float64(time.Second)), Color: red},
+		{Key: "state", Match: Equals("failed"), Color: red},
+		{Key: "took", Match: Below(float64(time.Millisecond)), Color: blue},
+	}
+	for _, tt := range []struct {
+		log  func(*slog.Logger)
+		want string // the value painted in the color, or nothing painted if empty
+		c    TerminalColor
+	}{
+		{func(l *slog.Logger) { l.Info("m", "status", 503) }, "503", red},
+		{func(l *slog.Logger) { l.Info("m", "status", 404) }, "404", yellow},
+		{func(l *slog.Logger) { l.Info("m", "status", HTTPStatus(502)) }, "502", red},
+		{func(l *slog.Logger) { l.Info("m", "status", 200) }, "", ""},
+		{func(l *slog.Logger) { l.Info("m", slog.Group("http", "status", 500)) }, "500", red},
+		{func(l *slog.Logger) { l.Info("m", slog.Group("http", "took", 2*time.Second)) }, `"2s"`, red},
+		{func(l *slog.Logger) { l.Info("m", "took", 2*time.Second) }, "", ""},
+		{func(l *slog.Logger) { l.Info("m", "took", time.Microsecond) }, `"1µs"`, blue},
+		{func(l *slog.Logger) { l.Info("m", "state", "failed") }, `"failed"`, red},
+		{func(l *slog.Logger) { l.Info("m", "state", "ok") }, "", ""},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime})
+		h.ColorProfile, h.ForceColor = ProfileTrueColor, true
+		h.ValueRules = rules
+		tt.log(slog.New(h))
+		out := buf.String()
+		if tt.want == "" {
+			for _, c := range []TerminalColor{red, yellow, blue} {
+				if strings.Contains(out, string(c)) {
+					t.Errorf("%q: painted by a rule", stripANSI(buf.Bytes()))
+				}
+			}
+			continue
+		}
+		if !strings.Contains(out, string(tt.c)+tt.want) {
+			t.Errorf("%q: %s not painted in %q", stripANSI(buf.Bytes()), tt.want, tt.c)
+		}
+	}
+}
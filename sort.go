@@ -0,0 +1,22 @@
+package colorjson
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+)
+
+// sortAttrs returns attrs sorted by key, and the attrs of its groups too.
+// Attrs with the same key keep their order. attrs is not modified.
+func sortAttrs(attrs []slog.Attr) []slog.Attr {
+	sorted := slices.Clone(attrs)
+	for i, a := range sorted {
+		if a.Value.Kind() == slog.KindGroup {
+			sorted[i].Value = slog.GroupValue(sortAttrs(a.Value.Group())...)
+		}
+	}
+	slices.SortStableFunc(sorted, func(a, b slog.Attr) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return sorted
+}
@@ -0,0 +1,124 @@
+package colorjson
+
+import (
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SourceFormat selects how the file of the "source" field is written when
+// HandlerOptions.AddSource is set.
+type SourceFormat int
+
+const (
+	SrcLongFile SourceFormat = iota // absolute file path, as reported by the runtime (default)
+	SrcRelative                     // path relative to the module root or one of SourcePrefixes, e.g. internal/db/conn.go
+	SrcFunc                         // package-qualified function and line as a string, e.g. db.(*Conn).Open:42
+)
+
+// relativeFile returns file trimmed of the first matching prefix, or of the
+// root of the module containing it. fn is the fully qualified function name
+// of the frame, used when the file is not on disk, e.g. in a deployed binary.
+func relativeFile(file, fn string, prefixes []string) string {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(file, p); ok {
+			return strings.TrimPrefix(rest, "/")
+		}
+	}
+	if root := moduleRoot(filepath.Dir(file)); root != "" {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	if dir, ok := pkgDir(fn); ok {
+		return dir + filepath.Base(file)
+	}
+	return file
+}
+
+// moduleRoots caches the module root found for each directory, "" if none.
+var moduleRoots sync.Map
+
+// moduleRoot returns the nearest parent of dir, including dir, that holds a
+// go.mod file.
+func moduleRoot(dir string) string {
+	if root, ok := moduleRoots.Load(dir); ok {
+		return root.(string)
+	}
+	root := ""
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	moduleRoots.Store(dir, root)
+	return root
+}
+
+// mainModule is the path of the main module from the binary's build info.
+var mainModule = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path
+	}
+	return ""
+})
+
+// pkgDir returns the directory, relative to the main module and ending in
+// "/", of the package of the function fn.
+func pkgDir(fn string) (string, bool) {
+	mod := mainModule()
+	if mod == "" {
+		return "", false
+	}
+	// the package path ends at the first "." after the last "/"
+	slash := strings.LastIndexByte(fn, '/')
+	dot := strings.IndexByte(fn[slash+1:], '.')
+	if dot < 0 {
+		return "", false
+	}
+	pkg := fn[:slash+1+dot]
+	if pkg == mod {
+		return "", true
+	}
+	rest, ok := strings.CutPrefix(pkg, mod+"/")
+	return rest + "/", ok
+}
+
+// shortFunc returns the function name qualified by its package name rather
+// than its import path, e.g. "db.(*Conn).Open".
+func shortFunc(fn string) string {
+	return fn[strings.LastIndexByte(fn, '/')+1:]
+}
+
+// frameFile returns the file of the frame formatted according to
+// h.SourceFormat.
+func (h *ColorJSONHandler) frameFile(f runtime.Frame) string {
+	if h.SourceFormat == SrcRelative {
+		return relativeFile(f.File, f.Function, h.SourcePrefixes)
+	}
+	return f.File
+}
+
+// sourceLink returns the SourceLink URL for the record's call site, or ""
+// if SourceLink is not set.
+func (h *ColorJSONHandler) sourceLink(r slog.Record) string {
+	if h.SourceLink == "" {
+		return ""
+	}
+	fs := runtime.CallersFrames([]uintptr{r.PC})
+	f, _ := fs.Next()
+	file := (&url.URL{Path: filepath.ToSlash(f.File)}).EscapedPath()
+	return strings.NewReplacer("{file}", file, "{line}", strconv.Itoa(f.Line)).Replace(h.SourceLink)
+}
@@ -0,0 +1,44 @@
+package colorjson
+
+import (
+	"io"
+	"log/slog"
+)
+
+// LevelSplitWriter routes records by level: records at or above Threshold
+// go to High and all others to Low, e.g. warnings and errors to stderr and
+// the rest to stdout:
+//
+//	w := colorjson.NewLevelSplitWriter(os.Stdout, os.Stderr, slog.LevelWarn)
+//	handler := colorjson.NewHandler(w, nil)
+type LevelSplitWriter struct {
+	Low       io.Writer    // output for records below Threshold
+	High      io.Writer    // output for records at or above Threshold
+	Threshold slog.Leveler // level at which records go to High, slog.LevelError if nil
+}
+
+// NewLevelSplitWriter creates a LevelSplitWriter.
+func NewLevelSplitWriter(low, high io.Writer, threshold slog.Leveler) *LevelSplitWriter {
+	return &LevelSplitWriter{Low: low, High: high, Threshold: threshold}
+}
+
+// Write writes p to Low, as the level is unknown.
+func (w *LevelSplitWriter) Write(p []byte) (int, error) {
+	return w.Low.Write(p)
+}
+
+// WriteLevel implements LevelWriter.
+func (w *LevelSplitWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	threshold := slog.LevelError // only errors go to High by default
+	if w.Threshold != nil {
+		threshold = w.Threshold.Level()
+	}
+	out := w.Low
+	if level >= threshold {
+		out = w.High
+	}
+	if lw, ok := out.(LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return out.Write(p)
+}
@@ -0,0 +1,160 @@
+package colorjson
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDriver answers every query with two rows and every exec with three
+// affected rows, and fails the queries containing "fail".
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(3), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return &fakeRows{n: 2}, nil
+}
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return fakeConn{}.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return fakeConn{}.QueryContext(context.Background(), s.query, nil)
+}
+
+type fakeRows struct{ n int }
+
+func (*fakeRows) Columns() []string { return []string{"id"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n == 0 {
+		return io.EOF
+	}
+	r.n--
+	dest[0] = int64(r.n)
+	return nil
+}
+
+// driverConnector opens the connections of a driver for sql.OpenDB, so the
+// test needn't register it.
+type driverConnector struct{ d driver.Driver }
+
+func (c driverConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c driverConnector) Driver() driver.Driver                        { return c.d }
+
+func TestWrapDriver(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts SQLOptions
+		run  func(ctx context.Context, db *sql.DB) error
+		want string // the "sql" group, without the duration
+		warn bool
+	}{
+		{"query", SQLOptions{}, func(ctx context.Context, db *sql.DB) error {
+			rows, err := db.QueryContext(ctx, "SELECT id FROM t WHERE a = ?", 1)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+			}
+			return rows.Close()
+		}, `{"query":"SELECT id FROM t WHERE a = ?","args":[1],"rows":2}`, false},
+		{"exec", SQLOptions{}, func(ctx context.Context, db *sql.DB) error {
+			_, err := db.ExecContext(ctx, "DELETE FROM t WHERE a = ?", "x")
+			return err
+		}, `{"query":"DELETE FROM t WHERE a = ?","args":["x"],"rows_affected":3}`, false},
+		{"prepared", SQLOptions{}, func(ctx context.Context, db *sql.DB) error {
+			stmt, err := db.PrepareContext(ctx, "UPDATE t SET a = ?")
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			_, err = stmt.ExecContext(ctx, 2)
+			return err
+		}, `{"query":"UPDATE t SET a = ?","args":[2],"rows_affected":3}`, false},
+		{"redacted", SQLOptions{RedactArgs: true}, func(ctx context.Context, db *sql.DB) error {
+			_, err := db.ExecContext(ctx, "UPDATE users SET password = ?", "hunter2")
+			return err
+		}, `{"query":"UPDATE users SET password = ?","args":["[REDACTED]"],"rows_affected":3}`, false},
+		{"slow", SQLOptions{SlowThreshold: time.Nanosecond}, func(ctx context.Context, db *sql.DB) error {
+			_, err := db.ExecContext(ctx, "VACUUM")
+			return err
+		}, `{"query":"VACUUM","args":[],"rows_affected":3,"slow":true}`, true},
+		{"error", SQLOptions{}, func(ctx context.Context, db *sql.DB) error {
+			_, err := db.ExecContext(ctx, "fail")
+			if err == nil {
+				return errors.New("no error")
+			}
+			return nil
+		}, `{"query":"fail","args":[],"error":"syntax error"}`, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.opts.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+			db := sql.OpenDB(driverConnector{WrapDriver(fakeDriver{}, tt.opts)})
+			defer db.Close()
+			if err := tt.run(t.Context(), db); err != nil {
+				t.Fatal(err)
+			}
+			var line struct {
+				Level string
+				SQL   map[string]any
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("%v: %s", err, buf.String())
+			}
+			if _, ok := line.SQL["duration"]; !ok {
+				t.Errorf("no duration in %s", buf.String())
+			}
+			delete(line.SQL, "duration")
+			got, _ := json.Marshal(line.SQL)
+			var want map[string]any
+			json.Unmarshal([]byte(tt.want), &want)
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("got  %s\nwant %s", got, wantJSON)
+			}
+			level := "INFO"
+			if tt.warn {
+				level = "WARN"
+			} else if strings.Contains(tt.want, `"error"`) {
+				level = "ERROR"
+			}
+			if line.Level != level {
+				t.Errorf("level %s, want %s", line.Level, level)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package colorjson
+
+import (
+	"runtime"
+	"strconv"
+)
+
+// maxStackFrames limits the number of frames captured for a stack trace
+const maxStackFrames = 32
+
+// stackTrace is a list of "function file:line" frames, rendered as an array
+// in the Stack color.
+type stackTrace []string
+
+// callerStack returns the current goroutine's stack as "function file:line"
+// entries. The trace starts at the frame with program counter pc (as found
+// in slog.Record.PC) or, when pc is zero or not on the stack, after skipping
+// skip frames.
+func callerStack(skip int, pc uintptr) stackTrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	pcs = pcs[:n]
+	if pc != 0 {
+		for i, p := range pcs {
+			if p == pc {
+				pcs = pcs[i:]
+				break
+			}
+		}
+	}
+
+	var stack stackTrace
+	frames := runtime.CallersFrames(pcs)
+	for len(stack) < maxStackFrames {
+		f, more := frames.Next()
+		if f.Function == "runtime.goexit" {
+			break
+		}
+		if f.Function != "" {
+			stack = append(stack, f.Function+" "+f.File+":"+strconv.Itoa(f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
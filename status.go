@@ -0,0 +1,37 @@
+package colorjson
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+// StatusCode is the status of a request, e.g. an HTTP response or a gRPC
+// call. ColorJSONHandler writes it in Colors.Status, or in the level color
+// when Level is WARN or above, so failed requests stand out.
+type StatusCode struct {
+	Code  int        // numeric code, e.g. 404
+	Name  string     // name of the code, e.g. "NotFound"; the number is written when empty
+	Level slog.Level // level the status is logged at
+}
+
+// String returns the name of the code, or the number without one.
+func (s StatusCode) String() string {
+	if s.Name == "" {
+		return strconv.Itoa(s.Code)
+	}
+	return s.Name
+}
+
+// MarshalJSON writes the code as a number, or as its name when it has one.
+func (s StatusCode) MarshalJSON() ([]byte, error) {
+	if s.Name == "" {
+		return strconv.AppendInt(nil, int64(s.Code), 10), nil
+	}
+	return strconv.AppendQuote(nil, s.Name), nil
+}
+
+// HTTPStatus returns the StatusCode of an HTTP response, at ERROR for 5xx,
+// WARN for 4xx and INFO otherwise.
+func HTTPStatus(code int) StatusCode {
+	return StatusCode{Code: code, Level: statusLevel(code)}
+}
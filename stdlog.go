@@ -0,0 +1,120 @@
+package colorjson
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// StdLogBridge is an io.Writer that turns the entries of a standard library
+// *log.Logger into records of a slog.Handler, so the output of legacy code is
+// formatted like the rest. The date, time and file:line written by the
+// logger's flags are parsed into the record's time and a "caller" attr.
+// Entries that don't parse are passed on whole as the message.
+type StdLogBridge struct {
+	// Prefix is the prefix of the logger, if it is set without the
+	// Lmsgprefix flag and so written before the date. It is moved to the
+	// start of the message, where Lmsgprefix puts it.
+	Prefix string
+
+	h     slog.Handler
+	level slog.Level
+}
+
+// NewStdLogBridge returns a bridge sending log entries to h at level, e.g.
+//
+//	log.SetOutput(colorjson.NewStdLogBridge(handler, slog.LevelInfo))
+func NewStdLogBridge(h slog.Handler, level slog.Level) *StdLogBridge {
+	return &StdLogBridge{h: h, level: level}
+}
+
+// Logger returns a *log.Logger writing to the bridge. Its entries get the
+// time they are handled at.
+func (b *StdLogBridge) Logger() *log.Logger {
+	return log.New(b, "", 0)
+}
+
+// Write implements io.Writer. Each call is a single entry, as *log.Logger
+// writes them.
+func (b *StdLogBridge) Write(p []byte) (int, error) {
+	ctx := context.Background()
+	if !b.h.Enabled(ctx, b.level) {
+		return len(p), nil
+	}
+	t, caller, msg := parseStdLog(strings.TrimSuffix(string(p), "\n"), b.Prefix)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	r := slog.NewRecord(t, b.level, msg, 0)
+	if caller != "" {
+		r.AddAttrs(slog.String("caller", caller))
+	}
+	return len(p), b.h.Handle(ctx, r)
+}
+
+// parseStdLog splits an entry written by *log.Logger with the Ldate, Ltime,
+// Lmicroseconds, Lshortfile and Llongfile flags into its time, file:line and
+// message, moving prefix, if the entry starts with it, to the message.
+// Parts that are missing are returned empty, and an entry whose date or time
+// doesn't parse is returned whole as the message. Times are taken to be
+// local, as without the LUTC flag.
+func parseStdLog(entry, prefix string) (t time.Time, caller, msg string) {
+	s, ok := strings.CutPrefix(entry, prefix)
+	if !ok {
+		s, prefix = entry, ""
+	}
+	var date, clock string
+	if len(s) >= 11 && s[4] == '/' && s[7] == '/' && s[10] == ' ' {
+		date, s = s[:10], s[11:]
+		if _, err := time.Parse("2006/01/02", date); err != nil {
+			return time.Time{}, "", entry
+		}
+	}
+	if len(s) >= 9 && s[2] == ':' && s[5] == ':' {
+		end := strings.IndexByte(s, ' ')
+		if end < 0 {
+			end = len(s)
+		}
+		clock, s = s[:end], strings.TrimPrefix(s[end:], " ")
+	}
+	// a date alone is less precise than the time the entry is handled at
+	if clock != "" {
+		layout, value := "15:04:05.999999", clock
+		if date != "" {
+			layout, value = "2006/01/02 "+layout, date+" "+clock
+		}
+		pt, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			return time.Time{}, "", entry
+		}
+		if date == "" {
+			// Ltime alone, today's date
+			now := time.Now()
+			pt = time.Date(now.Year(), now.Month(), now.Day(), pt.Hour(), pt.Minute(), pt.Second(), pt.Nanosecond(), time.Local)
+		}
+		t = pt
+	}
+	// file:line: message, where the file may start with a Windows drive
+	// letter, e.g. C:/src/main.go:12, but has no spaces
+	if i := strings.Index(s, ".go:"); i > 0 && !strings.Contains(s[:i], " ") {
+		if end := strings.Index(s[i+4:], ": "); end > 0 && isDigits(s[i+4:i+4+end]) {
+			caller, s = s[:i+4+end], s[i+4+end+2:]
+		}
+	}
+	return t, caller, prefix + s
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
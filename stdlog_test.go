@@ -0,0 +1,76 @@
+package colorjson
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStdLog(t *testing.T) {
+	for _, tt := range []struct {
+		prefix string
+		flags  int
+		caller string // part of the caller
+		timed  bool
+	}{
+		{"", 0, "", false},
+		{"", log.LstdFlags, "", true},
+		{"", log.Ldate, "", false},
+		{"", log.Ltime | log.Lmicroseconds | log.Lshortfile, "stdlog_test.go:", true},
+		{"", log.LstdFlags | log.Llongfile, "/stdlog_test.go:", true},
+		{"app: ", log.LstdFlags | log.Lshortfile, "stdlog_test.go:", true},
+		{"app: ", log.LstdFlags | log.Lshortfile | log.Lmsgprefix, "stdlog_test.go:", true},
+		{"[app] ", 0, "", false},
+	} {
+		var buf bytes.Buffer
+		l := log.New(&buf, tt.prefix, tt.flags)
+		// the bridge is told of prefixes written before the date
+		prefix := tt.prefix
+		if tt.flags&log.Lmsgprefix != 0 {
+			prefix = ""
+		}
+		// the message looks like a caller and a time, which are left in it
+		const msg = "retry x.go:3: at 12:00:00"
+		before := time.Now().Truncate(time.Second)
+		l.Print(msg)
+		tm, caller, got := parseStdLog(strings.TrimSuffix(buf.String(), "\n"), prefix)
+		if got != tt.prefix+msg {
+			t.Errorf("flags %d: message %q, want %q", tt.flags, got, tt.prefix+msg)
+		}
+		if !strings.Contains(caller, tt.caller) || (tt.caller == "") != (caller == "") {
+			t.Errorf("flags %d: caller %q, want one with %q", tt.flags, caller, tt.caller)
+		}
+		if tt.timed == tm.IsZero() || (tt.timed && tm.Before(before)) {
+			t.Errorf("flags %d: time %v", tt.flags, tm)
+		}
+	}
+
+	for _, tt := range []struct {
+		entry, caller, msg string
+	}{
+		{`2024/05/01 12:00:00 C:/src/app/main.go:12: started`, "C:/src/app/main.go:12", "started"},
+		{`C:\src\app\main.go:12: started`, `C:\src\app\main.go:12`, "started"},
+		{"2024/13/45 12:00:00 not a date", "", "2024/13/45 12:00:00 not a date"},
+		{"12:61:00 not a time", "", "12:61:00 not a time"},
+	} {
+		if _, caller, msg := parseStdLog(tt.entry, ""); caller != tt.caller || msg != tt.msg {
+			t.Errorf("parseStdLog(%q) = %q, %q; want %q, %q", tt.entry, caller, msg, tt.caller, tt.msg)
+		}
+	}
+}
+
+func TestStdLogBridge(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandler(&buf, nil)
+	h.ColorProfile, h.ForceColor = ProfileNone, false
+	b := NewStdLogBridge(h, 0)
+	b.Prefix = "app: "
+	log.New(b, "app: ", log.LstdFlags|log.Lshortfile).Print("started")
+	for _, want := range []string{`"msg":"app: started"`, `"caller":"stdlog_test.go:`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got %s, want %s", buf.String(), want)
+		}
+	}
+}
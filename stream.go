@@ -0,0 +1,78 @@
+package colorjson
+
+import (
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// partialWriter writes a record to the handler's output in parts, for
+// ColorJSONHandler.StreamThreshold. The output is locked from the first
+// part until the end of the record, so records are never interleaved.
+type partialWriter struct {
+	h      *ColorJSONHandler
+	level  slog.Level
+	time   time.Time
+	locked bool
+	err    error
+}
+
+// close unlocks the output if a part of the record was written.
+func (p *partialWriter) close() {
+	if p.locked {
+		p.h.mu.Unlock()
+	}
+}
+
+// write writes the part b of the record.
+func (p *partialWriter) write(b []byte) {
+	if !p.locked {
+		p.h.mu.Lock()
+		p.locked = true
+	}
+	if p.err == nil {
+		p.err = p.h.write(p.level, p.time, downgradeANSI(b, p.h.profile()))
+	}
+}
+
+// streamable reports whether records can be written to w in parts. Writers
+// taking each write as a message, and BufferedWriter, which keeps records
+// whole, need them in one write.
+func streamable(w io.Writer) bool {
+	switch w.(type) {
+	case LevelWriter, RecordWriter, *BufferedWriter:
+		return false
+	}
+	return true
+}
+
+// write writes b, a record or a part of one, to the handler's output. The
+// caller holds h.mu.
+func (h *ColorJSONHandler) write(level slog.Level, t time.Time, b []byte) error {
+	if rw, ok := h.out.(RecordWriter); ok {
+		_, err := rw.WriteRecord(level, t, b)
+		return err
+	}
+	if lw, ok := h.out.(LevelWriter); ok {
+		_, err := lw.WriteLevel(level, b)
+		return err
+	}
+	_, err := h.out.Write(b)
+	return err
+}
+
+// capValue returns s cut to at most max bytes, on a rune boundary, with a
+// marker giving its full length, e.g. "abc… (1048576 bytes)". s is
+// returned unchanged if max is zero or s is short enough.
+func capValue(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "… (" + strconv.Itoa(len(s)) + " bytes)"
+}
@@ -0,0 +1,186 @@
+package colorjson
+
+import "strconv"
+
+// Color is a terminal color for the foreground or background of a Style:
+// one of the 16 basic colors, a color of the 256-color palette from
+// Color256 or a 24-bit color from RGB. The zero value is the terminal's
+// default color.
+type Color uint32
+
+// The kind of a Color is stored in its top byte.
+const (
+	colorBasic Color = (iota + 1) << 24
+	color256
+	colorRGB
+)
+
+// The 16 basic colors.
+const (
+	Black Color = colorBasic | iota
+	Red
+	Green
+	Yellow
+	Blue
+	Magenta
+	Cyan
+	White
+	BrightBlack
+	BrightRed
+	BrightGreen
+	BrightYellow
+	BrightBlue
+	BrightMagenta
+	BrightCyan
+	BrightWhite
+)
+
+// Color256 returns the color n of the 256-color palette.
+func Color256(n uint8) Color {
+	return color256 | Color(n)
+}
+
+// RGB returns a 24-bit color.
+func RGB(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// Fg256 returns the escape sequence setting the foreground to color n of
+// the 256-color palette.
+func Fg256(n uint8) TerminalColor {
+	return Style{Fg: Color256(n)}.Color()
+}
+
+// Bg256 returns the escape sequence setting the background to color n of
+// the 256-color palette.
+func Bg256(n uint8) TerminalColor {
+	return Style{Bg: Color256(n)}.Color()
+}
+
+// FgRGB returns the escape sequence setting a 24-bit foreground color.
+func FgRGB(r, g, b uint8) TerminalColor {
+	return Style{Fg: RGB(r, g, b)}.Color()
+}
+
+// BgRGB returns the escape sequence setting a 24-bit background color.
+func BgRGB(r, g, b uint8) TerminalColor {
+	return Style{Bg: RGB(r, g, b)}.Color()
+}
+
+// appendSGR appends the SGR parameters selecting c, base being 30 for the
+// foreground and 40 for the background.
+func (c Color) appendSGR(b []byte, base int) []byte {
+	if len(b) > 0 {
+		b = append(b, ';')
+	}
+	switch c &^ 0xffffff {
+	case colorBasic:
+		n := int(c & 0xff)
+		if n >= 8 {
+			base += 60 // bright colors are 90-97 and 100-107
+		}
+		return strconv.AppendInt(b, int64(base+n%8), 10)
+	case color256:
+		b = strconv.AppendInt(b, int64(base+8), 10)
+		b = append(b, ";5;"...)
+		return strconv.AppendInt(b, int64(c&0xff), 10)
+	}
+	b = strconv.AppendInt(b, int64(base+8), 10)
+	b = append(b, ";2;"...)
+	b = strconv.AppendInt(b, int64(c>>16&0xff), 10)
+	b = append(b, ';')
+	b = strconv.AppendInt(b, int64(c>>8&0xff), 10)
+	b = append(b, ';')
+	return strconv.AppendInt(b, int64(c&0xff), 10)
+}
+
+// Style describes the appearance of a piece of text. Its Color method
+// renders it to the single escape sequence used in Colors, e.g.
+//
+//	Colors.Key = Style{Fg: Cyan, Bold: true}.Color() // "\033[1;36m"
+type Style struct {
+	Fg, Bg    Color
+	Bold      bool
+	Faint     bool
+	Italic    bool
+	Underline bool
+}
+
+// Color returns the escape sequence setting the style, or "" for the zero
+// Style, which leaves text uncolored.
+func (s Style) Color() TerminalColor {
+	var b []byte
+	for _, attr := range []struct {
+		on   bool
+		code byte
+	}{{s.Bold, '1'}, {s.Faint, '2'}, {s.Italic, '3'}, {s.Underline, '4'}} {
+		if attr.on {
+			if len(b) > 0 {
+				b = append(b, ';')
+			}
+			b = append(b, attr.code)
+		}
+	}
+	if s.Fg != 0 {
+		b = s.Fg.appendSGR(b, 30)
+	}
+	if s.Bg != 0 {
+		b = s.Bg.appendSGR(b, 40)
+	}
+	if len(b) == 0 {
+		return ""
+	}
+	return TerminalColor("\033[" + string(b) + "m")
+}
+
+// Renderer renders text in a style, as lipgloss.Style does.
+type Renderer interface {
+	Render(strs ...string) string
+}
+
+// Styler styles text, as termenv.Style does.
+type Styler interface {
+	Styled(s string) string
+}
+
+// FromStyle returns the color a Renderer or Styler, such as a lipgloss.Style
+// or a termenv.Style, starts its text with, so the styles of a TUI can be
+// reused for log colors:
+//
+//	h.Colors.Key = colorjson.FromStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true))
+//
+// Only colors and text attributes carry over; padding, borders and widths
+// are dropped. The style is rendered with its own color profile, so a
+// style whose renderer detected no color support, or any other value,
+// gives no color.
+func FromStyle(style any) TerminalColor {
+	const marker = "x"
+	var s string
+	switch st := style.(type) {
+	case Renderer:
+		s = st.Render(marker)
+	case Styler:
+		s = st.Styled(marker)
+	default:
+		return ""
+	}
+	// keep the SGR sequences written before the text
+	var c []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\033' || i+1 >= len(s) || s[i+1] != '[' {
+			if s[i] == marker[0] {
+				break
+			}
+			continue
+		}
+		end := i + 2
+		for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+			end++
+		}
+		if end < len(s) && s[end] == 'm' {
+			c = append(c, s[i:end+1]...)
+		}
+		i = end
+	}
+	return TerminalColor(c)
+}
@@ -0,0 +1,122 @@
+package colorjson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGOptions configures WriteSVG.
+type SVGOptions struct {
+	FontSize   float64 // font size in pixels, 14 if zero
+	Background string  // background color, "#1e1e1e" if empty
+	Foreground string  // default text color, "#d4d4d4" if empty
+	FontFamily string  // monospace font stack, "Menlo, Consolas, monospace" if empty
+}
+
+// WriteSVG renders the lines read from r, such as captured handler output,
+// as an SVG image of a terminal. ANSI colors and bold, dim, italic and
+// underline styles are preserved.
+func WriteSVG(w io.Writer, r io.Reader, opts SVGOptions) error {
+	if opts.FontSize == 0 {
+		opts.FontSize = 14
+	}
+	if opts.Background == "" {
+		opts.Background = "#1e1e1e"
+	}
+	if opts.Foreground == "" {
+		opts.Foreground = "#d4d4d4"
+	}
+	if opts.FontFamily == "" {
+		opts.FontFamily = "Menlo, Consolas, monospace"
+	}
+
+	var lines [][]styledSpan
+	cols := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		spans := parseANSI(strings.TrimRight(sc.Text(), "\r"))
+		n := 0
+		for _, s := range spans {
+			n += utf8.RuneCountInString(s.text)
+		}
+		cols = max(cols, n)
+		lines = append(lines, spans)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	charW := opts.FontSize * 0.6
+	lineH := opts.FontSize * 1.4
+	pad := opts.FontSize
+	width := 2*pad + float64(cols)*charW
+	height := 2*pad + float64(len(lines))*lineH
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", opts.Background)
+	fmt.Fprintf(&b, `<g font-family="%s" font-size="%g" fill="%s" xml:space="preserve">`+"\n",
+		escapeXML(opts.FontFamily), opts.FontSize, opts.Foreground)
+	for i, spans := range lines {
+		y := pad + float64(i)*lineH
+		// backgrounds first so the text is drawn on top
+		col := 0
+		for _, s := range spans {
+			n := utf8.RuneCountInString(s.text)
+			if s.style.bg != nil {
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n",
+					pad+float64(col)*charW, y, float64(n)*charW, lineH, hexColor(*s.style.bg))
+			}
+			col += n
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f">`, pad, y+opts.FontSize)
+		for _, s := range spans {
+			b.WriteString("<tspan")
+			if fg := s.style.fg; fg != nil {
+				// like most terminals, show bold basic colors in their bright variant
+				for k := range 8 {
+					if s.style.bold && fg == &ansiPalette[k] {
+						fg = &ansiPalette[k+8]
+					}
+				}
+				fmt.Fprintf(&b, ` fill="%s"`, hexColor(*fg))
+			}
+			if s.style.bold {
+				b.WriteString(` font-weight="bold"`)
+			}
+			if s.style.dim {
+				b.WriteString(` opacity="0.6"`)
+			}
+			if s.style.italic {
+				b.WriteString(` font-style="italic"`)
+			}
+			if s.style.underline {
+				b.WriteString(` text-decoration="underline"`)
+			}
+			b.WriteString(">")
+			b.WriteString(escapeXML(s.text))
+			b.WriteString("</tspan>")
+		}
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</g>\n</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func hexColor(rgb [3]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package colorjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyslogWriter sends each write as an RFC 5424 message to a local or remote
+// syslog server. ANSI color codes are stripped from the messages.
+type SyslogWriter struct {
+	Facility int    // syslog facility, 1 (user-level messages) by default
+	AppName  string // APP-NAME field of each message
+	Hostname string // HOSTNAME field, os.Hostname() by default
+
+	network string
+	raddr   string
+	mu      sync.Mutex
+	conn    net.Conn
+	dialed  string // network of conn, which for the local server is found by trying
+}
+
+// DialSyslog connects to a syslog server. If network is empty the local
+// syslog daemon is used through its unix socket.
+func DialSyslog(network, raddr, appName string) (*SyslogWriter, error) {
+	host, _ := os.Hostname()
+	w := &SyslogWriter{
+		Facility: 1,
+		AppName:  appName,
+		Hostname: host,
+		network:  network,
+		raddr:    raddr,
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewSyslogHandler returns an uncolored handler writing to a syslog server
+// connected with DialSyslog. Add it to ColorJSONHandler.Sinks to send records
+// to the system logger as well as the terminal.
+func NewSyslogHandler(network, raddr, appName string, opts *slog.HandlerOptions) (*ColorJSONHandler, error) {
+	w, err := DialSyslog(network, raddr, appName)
+	if err != nil {
+		return nil, err
+	}
+	h := NewHandler(w, opts)
+	h.Colors = Colors{}
+	return h, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	if w.network != "" {
+		c, err := net.Dial(w.network, w.raddr)
+		if err != nil {
+			return err
+		}
+		w.conn, w.dialed = c, w.network
+		return nil
+	}
+	for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		for _, network := range []string{"unixgram", "unix"} {
+			if c, err := net.Dial(network, path); err == nil {
+				w.conn, w.dialed = c, network
+				return nil
+			}
+		}
+	}
+	return errors.New("colorjson: no local syslog server found")
+}
+
+// Write sends p as an informational message stamped with the current time.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteRecord(slog.LevelInfo, time.Now(), p)
+}
+
+// WriteLevel sends p with the syslog severity matching level, stamped
+// with the current time.
+func (w *SyslogWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	return w.WriteRecord(level, time.Now(), p)
+}
+
+// WriteRecord sends p with the syslog severity matching level, stamped
+// with t, the time of the record. A zero t is sent as unknown.
+func (w *SyslogWriter) WriteRecord(level slog.Level, t time.Time, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.conn.Write(w.format(level, t, p)); err != nil {
+		// reconnect once, the server may have restarted
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := w.conn.Write(w.format(level, t, p)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog server.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// format builds the RFC 5424 message for p, framed for the network of the
+// connection. The caller holds w.mu.
+func (w *SyslogWriter) format(level slog.Level, t time.Time, p []byte) []byte {
+	p = bytes.TrimRight(stripANSI(p), "\r\n")
+	stamp := "-"
+	if !t.IsZero() {
+		stamp = t.Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - - ",
+		w.Facility*8+syslogSeverity(level), stamp,
+		nilValue(w.Hostname), nilValue(w.AppName), os.Getpid())
+	b.Write(p)
+
+	switch w.dialed {
+	case "tcp", "tcp4", "tcp6":
+		// octet counting framing (RFC 6587)
+		return append([]byte(strconv.Itoa(b.Len())+" "), b.Bytes()...)
+	case "unix":
+		// local daemons read stream sockets line by line, as log/syslog writes them
+		return append(b.Bytes(), '\n')
+	}
+	return b.Bytes()
+}
+
+// nilValue returns the RFC 5424 NILVALUE for empty header fields.
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogSeverity maps a slog level to a syslog severity.
+func syslogSeverity(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError+4:
+		return 2 // critical
+	case l >= slog.LevelError:
+		return 3 // error
+	case l >= slog.LevelWarn:
+		return 4 // warning
+	case l >= slog.LevelInfo:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
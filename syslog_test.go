@@ -0,0 +1,87 @@
+package colorjson
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC)
+	header := fmt.Sprintf("1 2024-05-01T12:00:00.123456Z host app %d - - ", os.Getpid())
+	for _, tt := range []struct {
+		network string
+		level   slog.Level
+		t       time.Time
+		want    string
+	}{
+		{"udp", slog.LevelInfo, ts, "<14>" + header + "msg"},
+		{"unixgram", slog.LevelDebug, ts, "<15>" + header + "msg"},
+		{"unix", slog.LevelWarn, ts, "<12>" + header + "msg\n"},
+		{"tcp", slog.LevelError, ts, fmt.Sprintf("%d <11>%smsg", len("<11>"+header+"msg"), header)},
+		{"udp", slog.LevelError + 4, ts, "<10>" + header + "msg"},
+		{"udp", slog.LevelInfo, time.Time{}, fmt.Sprintf("<14>1 - host app %d - - msg", os.Getpid())},
+	} {
+		w := &SyslogWriter{Facility: 1, AppName: "app", Hostname: "host", dialed: tt.network}
+		if got := string(w.format(tt.level, tt.t, []byte("\033[31mmsg\033[0m\n"))); got != tt.want {
+			t.Errorf("%s, %v: got %q want %q", tt.network, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		var msgs []string
+		for range 2 {
+			// octet counting: the length, a space and the message
+			var n int
+			if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+				break
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				break
+			}
+			msgs = append(msgs, string(b))
+		}
+		got <- msgs
+	}()
+
+	h, err := NewSyslogHandler("tcp", ln.Addr().String(), "app", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.out.(*SyslogWriter).Close()
+	l := slog.New(h)
+	l.Info("first")
+	l.Warn("second\nline")
+	msgs := <-got
+	if len(msgs) != 2 {
+		t.Fatalf("got messages %q, want 2", msgs)
+	}
+	if !strings.HasPrefix(msgs[0], "<14>1 ") || !strings.Contains(msgs[0], `"msg":"first"`) {
+		t.Errorf("first message %q", msgs[0])
+	}
+	if !strings.HasPrefix(msgs[1], "<12>1 ") || !strings.Contains(msgs[1], `"msg":"second\nline"`) {
+		t.Errorf("second message %q", msgs[1])
+	}
+}
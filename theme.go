@@ -0,0 +1,181 @@
+package colorjson
+
+import (
+	"slices"
+	"sync"
+)
+
+// defaultColors are the colors of a new handler.
+var defaultColors = Colors{
+	String:     GreenColor,
+	Number:     YellowColor,
+	Boolean:    MagentaColor,
+	Null:       WhiteColor,
+	Key:        CyanColor,
+	Brace:      BBlueColor,
+	Error:      RedColor,
+	Stack:      GrayColor,
+	Duration:   BlueColor,
+	Status:     BMagentaColor,
+	Changed:    Style{Fg: Black, Bg: BrightGreen}.Color(),
+	Threshold:  Style{Fg: Black, Bg: Yellow}.Color(),
+	Dim:        DimColor,
+	LevelInfo:  BWhiteColor,
+	LevelDebug: BCyanColor,
+	LevelWarn:  BYellowColor,
+	LevelError: BRedColor,
+}
+
+// redGreenSafe avoids telling values apart by red and green, which look
+// alike with deuteranopia and protanopia, using a blue/orange palette.
+var redGreenSafe = Colors{
+	String:     Style{Fg: Color256(39)}.Color(),  // blue
+	Number:     Style{Fg: Color256(214)}.Color(), // orange
+	Boolean:    Style{Fg: Color256(183)}.Color(), // lavender
+	Null:       GrayColor,
+	Key:        Style{Fg: Color256(153)}.Color(), // pale blue
+	Brace:      WhiteColor,
+	Error:      Style{Fg: Color256(208), Bold: true}.Color(), // dark orange
+	Stack:      GrayColor,
+	Duration:   Style{Fg: Color256(75)}.Color(), // sky blue
+	Dim:        DimColor,
+	LevelInfo:  BWhiteColor,
+	LevelDebug: Style{Fg: Color256(75), Bold: true}.Color(),
+	LevelWarn:  Style{Fg: Color256(220), Bold: true}.Color(), // yellow
+	LevelError: Style{Fg: Color256(208), Bold: true, Underline: true}.Color(),
+}
+
+// blueYellowSafe avoids telling values apart by blue and yellow, which look
+// alike with tritanopia, using a red/cyan palette.
+var blueYellowSafe = Colors{
+	String:     Style{Fg: Color256(51)}.Color(),  // cyan
+	Number:     Style{Fg: Color256(211)}.Color(), // pink
+	Boolean:    Style{Fg: Color256(255), Italic: true}.Color(),
+	Null:       GrayColor,
+	Key:        Style{Fg: Color256(159)}.Color(), // pale cyan
+	Brace:      WhiteColor,
+	Error:      Style{Fg: Color256(196), Bold: true}.Color(), // red
+	Stack:      GrayColor,
+	Duration:   Style{Fg: Color256(218)}.Color(), // light pink
+	Dim:        DimColor,
+	LevelInfo:  BWhiteColor,
+	LevelDebug: Style{Fg: Color256(51), Bold: true}.Color(),
+	LevelWarn:  Style{Fg: Color256(205), Bold: true}.Color(), // magenta
+	LevelError: Style{Fg: Color256(196), Bold: true, Underline: true}.Color(),
+}
+
+var (
+	themesMu sync.RWMutex
+	themes   = map[string]Colors{
+		"default":      defaultColors,
+		"dark":         defaultColors,
+		"light":        lightColors,
+		"none":         {},
+		"keys":         defaultColors.KeysOnly(),
+		"deuteranopia": redGreenSafe,
+		"protanopia":   redGreenSafe,
+		"tritanopia":   blueYellowSafe,
+	}
+)
+
+// RegisterTheme adds a named set of colors to the theme registry, replacing
+// any theme of the same name.
+func RegisterTheme(name string, c Colors) {
+	themesMu.Lock()
+	defer themesMu.Unlock()
+	themes[name] = c
+}
+
+// Theme returns the colors registered as name. Built-in themes are
+// "default" (also "dark"), "light", "none" (no colors), "keys" (only keys
+// and levels colored) and the colorblind-friendly "deuteranopia",
+// "protanopia" and "tritanopia".
+func Theme(name string) (Colors, bool) {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	c, ok := themes[name]
+	c.Rainbow = slices.Clone(c.Rainbow)
+	c.Braces = slices.Clone(c.Braces)
+	return c, ok
+}
+
+// Themes returns the sorted names of the registered themes.
+func Themes() []string {
+	themesMu.RLock()
+	defer themesMu.RUnlock()
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ColorDefault is a copy of the default colors of a new handler, a starting
+// point for custom colors:
+//
+//	h.Colors = colorjson.ColorDefault.WithKey(colorjson.BlueColor)
+var ColorDefault = defaultColors
+
+// WithKey returns a copy of c with keys in color k.
+func (c Colors) WithKey(k TerminalColor) Colors {
+	c.Key = k
+	return c
+}
+
+// WithLevels returns a copy of c with the given level colors.
+func (c Colors) WithLevels(info, warn, err, debug TerminalColor) Colors {
+	c.LevelInfo, c.LevelWarn, c.LevelError, c.LevelDebug = info, warn, err, debug
+	return c
+}
+
+// KeysOnly returns the colors of c for keys and level values only, leaving
+// every other value and the punctuation plain so values copy cleanly. Dim
+// is kept for the keys of FormatConsole.
+func (c Colors) KeysOnly() Colors {
+	return Colors{
+		Key:        c.Key,
+		Dim:        c.Dim,
+		LevelInfo:  c.LevelInfo,
+		LevelDebug: c.LevelDebug,
+		LevelWarn:  c.LevelWarn,
+		LevelError: c.LevelError,
+	}
+}
+
+// Merge returns a copy of c with the colors set in other, the non-empty
+// ones, replacing those of c.
+func (c Colors) Merge(other Colors) Colors {
+	for _, f := range []struct{ dst, src *TerminalColor }{
+		{&c.String, &other.String},
+		{&c.Number, &other.Number},
+		{&c.Boolean, &other.Boolean},
+		{&c.Null, &other.Null},
+		{&c.Key, &other.Key},
+		{&c.Brace, &other.Brace},
+		{&c.Punctuation, &other.Punctuation},
+		{&c.Error, &other.Error},
+		{&c.Stack, &other.Stack},
+		{&c.Duration, &other.Duration},
+		{&c.Time, &other.Time},
+		{&c.Status, &other.Status},
+		{&c.Changed, &other.Changed},
+		{&c.Threshold, &other.Threshold},
+		{&c.Dim, &other.Dim},
+		{&c.LevelInfo, &other.LevelInfo},
+		{&c.LevelDebug, &other.LevelDebug},
+		{&c.LevelWarn, &other.LevelWarn},
+		{&c.LevelError, &other.LevelError},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(other.Rainbow) > 0 {
+		c.Rainbow = slices.Clone(other.Rainbow)
+	}
+	if len(other.Braces) > 0 {
+		c.Braces = slices.Clone(other.Braces)
+	}
+	return c
+}
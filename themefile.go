@@ -0,0 +1,203 @@
+package colorjson
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ThemeSchema is the JSON Schema of theme files.
+//
+//go:embed theme.schema.json
+var ThemeSchema []byte
+
+// ThemeFile is the content of a theme file, e.g.
+//
+//	{
+//	  "name": "solarized",
+//	  "colors": {
+//	    "key": "#268bd2",
+//	    "string": "green",
+//	    "level_error": {"fg": "white", "bg": "red", "bold": true}
+//	  }
+//	}
+//
+// Colors not set in the file are left empty. See ThemeSchema.
+type ThemeFile struct {
+	Name    string                `json:"name,omitempty"`
+	Colors  map[string]ThemeStyle `json:"colors"`
+	Rainbow []ThemeStyle          `json:"rainbow,omitempty"`
+	Braces  []ThemeStyle          `json:"braces,omitempty"`
+}
+
+// ThemeStyle is a color of a theme file. It is written as a foreground color
+// or as an object with "fg", "bg", "bold", "faint", "italic" and "underline".
+// A color is a basic color name such as "red" or "bright_cyan", a 256-color
+// palette index such as "208" or a hex color such as "#ff8800".
+type ThemeStyle struct {
+	Fg        string `json:"fg,omitempty"`
+	Bg        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Faint     bool   `json:"faint,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+}
+
+// UnmarshalJSON accepts a color string as well as a style object.
+func (s *ThemeStyle) UnmarshalJSON(b []byte) error {
+	var fg string
+	if err := json.Unmarshal(b, &fg); err == nil {
+		*s = ThemeStyle{Fg: fg}
+		return nil
+	}
+	type style ThemeStyle // without the UnmarshalJSON method
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*style)(s))
+}
+
+// themeKeys maps the color names of theme files to the fields of Colors.
+var themeKeys = map[string]func(*Colors) *TerminalColor{
+	"string":      func(c *Colors) *TerminalColor { return &c.String },
+	"number":      func(c *Colors) *TerminalColor { return &c.Number },
+	"boolean":     func(c *Colors) *TerminalColor { return &c.Boolean },
+	"null":        func(c *Colors) *TerminalColor { return &c.Null },
+	"key":         func(c *Colors) *TerminalColor { return &c.Key },
+	"brace":       func(c *Colors) *TerminalColor { return &c.Brace },
+	"punctuation": func(c *Colors) *TerminalColor { return &c.Punctuation },
+	"error":       func(c *Colors) *TerminalColor { return &c.Error },
+	"stack":       func(c *Colors) *TerminalColor { return &c.Stack },
+	"duration":    func(c *Colors) *TerminalColor { return &c.Duration },
+	"time":        func(c *Colors) *TerminalColor { return &c.Time },
+	"status":      func(c *Colors) *TerminalColor { return &c.Status },
+	"changed":     func(c *Colors) *TerminalColor { return &c.Changed },
+	"threshold":   func(c *Colors) *TerminalColor { return &c.Threshold },
+	"dim":         func(c *Colors) *TerminalColor { return &c.Dim },
+	"level_info":  func(c *Colors) *TerminalColor { return &c.LevelInfo },
+	"level_debug": func(c *Colors) *TerminalColor { return &c.LevelDebug },
+	"level_warn":  func(c *Colors) *TerminalColor { return &c.LevelWarn },
+	"level_error": func(c *Colors) *TerminalColor { return &c.LevelError },
+}
+
+// colorNames are the basic colors by name.
+var colorNames = map[string]Color{
+	"black": Black, "red": Red, "green": Green, "yellow": Yellow,
+	"blue": Blue, "magenta": Magenta, "cyan": Cyan, "white": White,
+	"bright_black": BrightBlack, "bright_red": BrightRed, "bright_green": BrightGreen,
+	"bright_yellow": BrightYellow, "bright_blue": BrightBlue, "bright_magenta": BrightMagenta,
+	"bright_cyan": BrightCyan, "bright_white": BrightWhite,
+	"gray": BrightBlack, "grey": BrightBlack,
+}
+
+// parseColor parses a color of a theme file; "" is the default color.
+func parseColor(s string) (Color, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if c, ok := colorNames[s]; ok {
+		return c, nil
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return 0, fmt.Errorf("invalid hex color %q, want #rgb or #rrggbb", s)
+		}
+		return RGB(uint8(n>>16), uint8(n>>8), uint8(n)), nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return Color256(uint8(n)), nil
+	}
+	return 0, fmt.Errorf("unknown color %q, want a color name, a 256-color index or #rrggbb", s)
+}
+
+// style converts s to a Style.
+func (s ThemeStyle) style() (Style, error) {
+	fg, err := parseColor(s.Fg)
+	if err != nil {
+		return Style{}, fmt.Errorf("fg: %w", err)
+	}
+	bg, err := parseColor(s.Bg)
+	if err != nil {
+		return Style{}, fmt.Errorf("bg: %w", err)
+	}
+	return Style{Fg: fg, Bg: bg, Bold: s.Bold, Faint: s.Faint, Italic: s.Italic, Underline: s.Underline}, nil
+}
+
+// Validate reports every problem of the theme, such as unknown color names
+// or malformed colors, each prefixed with its location in the file.
+func (t ThemeFile) Validate() error {
+	var errs []error
+	keys := make([]string, 0, len(t.Colors))
+	for k := range t.Colors {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if _, ok := themeKeys[k]; !ok {
+			errs = append(errs, fmt.Errorf("colors.%s: unknown color name", k))
+			continue
+		}
+		if _, err := t.Colors[k].style(); err != nil {
+			errs = append(errs, fmt.Errorf("colors.%s.%w", k, err))
+		}
+	}
+	for i, s := range t.Rainbow {
+		if _, err := s.style(); err != nil {
+			errs = append(errs, fmt.Errorf("rainbow[%d].%w", i, err))
+		}
+	}
+	for i, s := range t.Braces {
+		if _, err := s.style(); err != nil {
+			errs = append(errs, fmt.Errorf("braces[%d].%w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Theme converts the theme file to Colors.
+func (t ThemeFile) Theme() (Colors, error) {
+	if err := t.Validate(); err != nil {
+		return Colors{}, err
+	}
+	var c Colors
+	for k, s := range t.Colors {
+		st, _ := s.style()
+		*themeKeys[k](&c) = st.Color()
+	}
+	for _, s := range t.Rainbow {
+		st, _ := s.style()
+		c.Rainbow = append(c.Rainbow, st.Color())
+	}
+	for _, s := range t.Braces {
+		st, _ := s.style()
+		c.Braces = append(c.Braces, st.Color())
+	}
+	return c, nil
+}
+
+// LoadTheme reads a theme file, validates it and returns its colors. When
+// the file has a name, the theme is also registered under it.
+func LoadTheme(r io.Reader) (Colors, error) {
+	var t ThemeFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return Colors{}, fmt.Errorf("colorjson: theme: %w", err)
+	}
+	c, err := t.Theme()
+	if err != nil {
+		return Colors{}, fmt.Errorf("colorjson: theme: %w", err)
+	}
+	if t.Name != "" {
+		RegisterTheme(t.Name, c)
+	}
+	return c, nil
+}
@@ -0,0 +1,45 @@
+package colorjson
+
+import "sync"
+
+// treeState holds the groups of the previous record, for TreeMode. It is
+// shared by the handlers derived with WithAttrs and WithGroup.
+type treeState struct {
+	mu     sync.Mutex
+	groups []string
+}
+
+// enter records groups as those of the latest record and returns how many
+// of them, from the outermost, the previous record was in too.
+func (t *treeState) enter(groups []string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for n < len(groups) && n < len(t.groups) && groups[n] == t.groups[n] {
+		n++
+	}
+	t.groups = append(t.groups[:0], groups...)
+	return n
+}
+
+// treePrefix writes a "├─ name" line for each of groups past the first
+// shared ones, which the previous record was in, and then the indentation
+// of a record in groups.
+func (e *encoder) treePrefix(groups []string, shared int) {
+	for i := shared; i < len(groups); i++ {
+		e.treeIndent(i)
+		e.colored(e.colors.Brace, []byte("├─ "))
+		e.appendColor(e.colors.Key)
+		e.buf = append(e.buf, groups[i]...)
+		e.reset(e.colors.Key)
+		e.buf = append(e.buf, e.eol...)
+	}
+	e.treeIndent(len(groups))
+}
+
+// treeIndent writes the indentation of n levels of a tree.
+func (e *encoder) treeIndent(n int) {
+	for range n {
+		e.colored(e.colors.Brace, []byte("│  "))
+	}
+}
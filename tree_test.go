@@ -0,0 +1,53 @@
+package colorjson
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestTreeMode(t *testing.T) {
+	for _, tt := range []struct {
+		format Format
+		want   string
+	}{
+		{FormatConsole, `INF start
+├─ req
+│  INF parsing req.id=7
+│  ├─ db
+│  │  INF query req.id=7 req.db.rows=3
+│  │  INF query req.id=7 req.db.rows=4
+│  INF done req.id=7
+├─ other
+│  INF next
+INF end
+`},
+		// machine formats stay flat
+		{FormatJSON, `{"level":"INFO","msg":"start"}
+{"level":"INFO","msg":"parsing","req":{"id":7}}
+{"level":"INFO","msg":"query","req":{"id":7,"db":{"rows":3}}}
+{"level":"INFO","msg":"query","req":{"id":7,"db":{"rows":4}}}
+{"level":"INFO","msg":"done","req":{"id":7}}
+{"level":"INFO","msg":"next"}
+{"level":"INFO","msg":"end"}
+`},
+	} {
+		var buf bytes.Buffer
+		h := NewHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime})
+		h.Format, h.TreeMode = tt.format, true
+		h.ColorProfile, h.ForceColor = ProfileNone, false
+		l := slog.New(h)
+		l.Info("start")
+		req := l.WithGroup("req").With("id", 7)
+		req.Info("parsing")
+		db := req.WithGroup("db")
+		db.Info("query", "rows", 3)
+		db.Info("query", "rows", 4)
+		req.Info("done")
+		l.WithGroup("other").Info("next")
+		l.Info("end")
+		if got := buf.String(); got != tt.want {
+			t.Errorf("format %d: got\n%s\nwant\n%s", tt.format, got, tt.want)
+		}
+	}
+}